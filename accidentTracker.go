@@ -0,0 +1,240 @@
+package core
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const DefaultMaxClosedAccidents = 10000
+
+type hostAccidents struct {
+	open      map[ObjectAccidentKey]*ObjectAccidentEventInfo
+	closed    []*ObjectAccidentEventInfo
+	closedIds map[closedAccidentKey]bool
+}
+
+// closedAccidentKey определяет завершенный инцидент для исключения повторов события завершения
+type closedAccidentKey struct {
+	key     ObjectAccidentKey
+	endTime int64
+}
+
+func getClosedAccidentKey(accident *ObjectAccidentEventInfo) closedAccidentKey {
+	return closedAccidentKey{
+		key:     ObjectAccidentKey{ObjectId: accident.ObjectId, AccidentId: accident.AlgorithmId},
+		endTime: accident.EndTime.UnixNano()}
+}
+
+// AccidentTracker хранит открытые и завершенные инциденты объектов для каждого хоста.
+// Выход из АНР (AccidentType 1) имеет ключ с AccidentId = -1, срабатывание АП (AccidentType 2) -
+// ключ с идентификатором АП.
+// Для каждого хоста хранится не более maxClosed завершенных инцидентов, самые старые удаляются.
+type AccidentTracker struct {
+	mutex     sync.RWMutex
+	hosts     map[int32]*hostAccidents
+	maxClosed int
+}
+
+func NewAccidentTracker() *AccidentTracker {
+	return &AccidentTracker{hosts: make(map[int32]*hostAccidents), maxClosed: DefaultMaxClosedAccidents}
+}
+
+// SetMaxClosedAccidents задает количество хранимых завершенных инцидентов хоста, 0 - без ограничения
+func (tracker *AccidentTracker) SetMaxClosedAccidents(maxClosed int) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	tracker.maxClosed = maxClosed
+	for _, host := range tracker.hosts {
+		tracker.limitClosed(host)
+	}
+}
+
+// Apply обрабатывает сетевой пакет. Пакеты без событий и состояний инцидентов игнорируются.
+func (tracker *AccidentTracker) Apply(networkPackage *NetworkPackage) error {
+	switch networkPackage.Data.Format {
+	case PackageFormatFullAccidentStates:
+		fullState, err := networkPackage.Data.ParseFullAccidentStatePackage()
+		if err != nil {
+			return err
+		}
+		tracker.ApplyFullState(networkPackage.HostId, fullState, networkPackage.Data.GetPackageTime())
+
+	case PackageFormatEvents:
+		events, err := networkPackage.Data.ParseEventsPackage()
+		if err != nil {
+			return err
+		}
+		tracker.ApplyEvents(networkPackage.HostId, events.ObjectAccidentsChangeState)
+	}
+
+	return nil
+}
+
+func (tracker *AccidentTracker) ApplyEvents(hostId int32, events map[ObjectAccidentKey]*ObjectAccidentEventInfo) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	host := tracker.getHost(hostId)
+	for key, event := range events {
+		tracker.applyEvent(host, key, event)
+	}
+	tracker.limitClosed(host)
+}
+
+// ApplyFullState заменяет открытые инциденты хоста полным состоянием. Открытые инциденты, отсутствующие
+// в полном состоянии, завершаются временем состояния с признаком IsEndMissing.
+func (tracker *AccidentTracker) ApplyFullState(hostId int32,
+	fullState map[ObjectAccidentKey]*ObjectAccidentEventInfo, stateTime time.Time) {
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	host := tracker.getHost(hostId)
+	for key, accident := range host.open {
+		if _, ok := fullState[key]; ok {
+			continue
+		}
+		closed := *accident
+		closed.EndTime = stateTime
+		closed.IsEndMissing = true
+		tracker.applyEvent(host, key, &closed)
+	}
+	host.open = make(map[ObjectAccidentKey]*ObjectAccidentEventInfo)
+
+	for key, event := range fullState {
+		tracker.applyEvent(host, key, event)
+	}
+	tracker.limitClosed(host)
+}
+
+func (tracker *AccidentTracker) getHost(hostId int32) *hostAccidents {
+	host, ok := tracker.hosts[hostId]
+	if !ok {
+		host = &hostAccidents{
+			open:      make(map[ObjectAccidentKey]*ObjectAccidentEventInfo),
+			closedIds: make(map[closedAccidentKey]bool)}
+		tracker.hosts[hostId] = host
+	}
+	return host
+}
+
+func (tracker *AccidentTracker) applyEvent(host *hostAccidents, key ObjectAccidentKey, event *ObjectAccidentEventInfo) {
+	accident := *event
+
+	if accident.IsOpen() {
+		host.open[key] = &accident
+		return
+	}
+
+	delete(host.open, key)
+
+	// Повторное событие завершения того же инцидента
+	closedId := getClosedAccidentKey(&accident)
+	if host.closedIds[closedId] {
+		return
+	}
+	host.closedIds[closedId] = true
+	host.closed = append(host.closed, &accident)
+}
+
+func (tracker *AccidentTracker) limitClosed(host *hostAccidents) {
+	if tracker.maxClosed <= 0 || len(host.closed) <= tracker.maxClosed {
+		return
+	}
+
+	// Удаляем инциденты, завершившиеся раньше остальных
+	sort.SliceStable(host.closed, func(i, j int) bool { return host.closed[i].EndTime.Before(host.closed[j].EndTime) })
+
+	removed := len(host.closed) - tracker.maxClosed
+	for _, accident := range host.closed[:removed] {
+		delete(host.closedIds, getClosedAccidentKey(accident))
+	}
+	host.closed = append([]*ObjectAccidentEventInfo(nil), host.closed[removed:]...)
+}
+
+// GetOpenAccidents возвращает незавершенные инциденты хоста, упорядоченные по времени начала
+func (tracker *AccidentTracker) GetOpenAccidents(hostId int32) []*ObjectAccidentEventInfo {
+	tracker.mutex.RLock()
+	defer tracker.mutex.RUnlock()
+
+	host, ok := tracker.hosts[hostId]
+	if !ok {
+		return nil
+	}
+
+	var result []*ObjectAccidentEventInfo
+	for _, accident := range host.open {
+		accidentCopy := *accident
+		result = append(result, &accidentCopy)
+	}
+
+	sortAccidents(result)
+	return result
+}
+
+// GetAccidents возвращает инциденты хоста (открытые и завершенные), пересекающиеся с интервалом [from, to]
+func (tracker *AccidentTracker) GetAccidents(hostId int32, from time.Time, to time.Time) []*ObjectAccidentEventInfo {
+	tracker.mutex.RLock()
+	defer tracker.mutex.RUnlock()
+
+	host, ok := tracker.hosts[hostId]
+	if !ok {
+		return nil
+	}
+
+	var result []*ObjectAccidentEventInfo
+
+	for _, accident := range host.open {
+		if !accident.StartTime.After(to) {
+			accidentCopy := *accident
+			result = append(result, &accidentCopy)
+		}
+	}
+
+	for _, accident := range host.closed {
+		if !accident.StartTime.After(to) && !accident.EndTime.Before(from) {
+			accidentCopy := *accident
+			result = append(result, &accidentCopy)
+		}
+	}
+
+	sortAccidents(result)
+	return result
+}
+
+// RemoveClosedBefore удаляет завершенные инциденты, закончившиеся ранее указанного времени
+func (tracker *AccidentTracker) RemoveClosedBefore(endTime time.Time) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	for _, host := range tracker.hosts {
+		var closed []*ObjectAccidentEventInfo
+		for _, accident := range host.closed {
+			if !accident.EndTime.Before(endTime) {
+				closed = append(closed, accident)
+			} else {
+				delete(host.closedIds, getClosedAccidentKey(accident))
+			}
+		}
+		host.closed = closed
+	}
+}
+
+// FilterAccidentsByType оставляет только инциденты указанного типа
+func FilterAccidentsByType(accidents []*ObjectAccidentEventInfo, accidentType byte) []*ObjectAccidentEventInfo {
+	var result []*ObjectAccidentEventInfo
+	for _, accident := range accidents {
+		if accident.AccidentType == accidentType {
+			result = append(result, accident)
+		}
+	}
+	return result
+}
+
+func sortAccidents(accidents []*ObjectAccidentEventInfo) {
+	sort.Slice(accidents, func(i, j int) bool {
+		return accidents[i].StartTime.Before(accidents[j].StartTime)
+	})
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseOpenAccident(t *testing.T) {
+	_, timeSlice := getTimeAndSlice(time.Now())
+
+	data := append([]byte{PackageEventTypeAccidentInfo, AccidentTypeProtectiveAlgorithm}, getSliceFromInt32(12)...)
+	data = append(data, 200, 0, 0, 0)
+	data = append(data, timeSlice...)
+	data = append(data, 0, 0, 0, 0, 0, 0, 0, 0)
+
+	events, err := (&DataPackage{Format: PackageFormatEvents, Data: data}).ParseEventsPackage()
+	assert.Nil(t, err)
+
+	accident := events.ObjectAccidentsChangeState[ObjectAccidentKey{ObjectId: 200, AccidentId: 12}]
+	assert.NotNil(t, accident)
+	assert.True(t, accident.IsOpen())
+	assert.True(t, accident.EndTime.IsZero())
+	assert.True(t, accident.IsProtectiveAlgorithm())
+	assert.False(t, accident.IsNwaLeave())
+}
+
+func TestAccidentTracker(t *testing.T) {
+	now := time.Now()
+	nwaKey := ObjectAccidentKey{ObjectId: 100, AccidentId: -1}
+	apKey := ObjectAccidentKey{ObjectId: 100, AccidentId: 12}
+
+	tracker := NewAccidentTracker()
+
+	tracker.ApplyEvents(1, map[ObjectAccidentKey]*ObjectAccidentEventInfo{
+		nwaKey: {ObjectId: 100, AccidentType: AccidentTypeNwaLeave, AlgorithmId: -1, StartTime: now.Add(-time.Hour)},
+		apKey:  {ObjectId: 100, AccidentType: AccidentTypeProtectiveAlgorithm, AlgorithmId: 12, StartTime: now.Add(-time.Minute)},
+	})
+
+	open := tracker.GetOpenAccidents(1)
+	assert.Len(t, open, 2)
+	assert.Equal(t, AccidentTypeNwaLeave, open[0].AccidentType)
+	assert.Len(t, FilterAccidentsByType(open, AccidentTypeProtectiveAlgorithm), 1)
+
+	tracker.ApplyEvents(1, map[ObjectAccidentKey]*ObjectAccidentEventInfo{
+		nwaKey: {ObjectId: 100, AccidentType: AccidentTypeNwaLeave, AlgorithmId: -1,
+			StartTime: now.Add(-time.Hour), EndTime: now.Add(-time.Minute * 30)},
+	})
+
+	open = tracker.GetOpenAccidents(1)
+	assert.Len(t, open, 1)
+	assert.Equal(t, int32(12), open[0].AlgorithmId)
+
+	closed := tracker.GetAccidents(1, now.Add(-time.Hour*2), now.Add(-time.Minute*45))
+	assert.Len(t, closed, 1)
+	assert.Equal(t, time.Minute*30, closed[0].Duration(now))
+
+	assert.Len(t, tracker.GetAccidents(1, now.Add(-time.Hour*2), now), 2)
+	assert.Len(t, tracker.GetAccidents(1, now.Add(-time.Minute*20), now), 1)
+
+	tracker.RemoveClosedBefore(now)
+	assert.Len(t, tracker.GetAccidents(1, now.Add(-time.Hour*2), now), 1)
+}
+
+func TestAccidentTrackerFullState(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	_, startSlice := getTimeAndSlice(start)
+
+	tracker := NewAccidentTracker()
+	tracker.ApplyEvents(1, map[ObjectAccidentKey]*ObjectAccidentEventInfo{
+		{ObjectId: 100, AccidentId: -1}: {ObjectId: 100, AccidentType: AccidentTypeNwaLeave, AlgorithmId: -1, StartTime: start},
+	})
+
+	// Событие завершения пропущено, полное состояние содержит только АП объекта 200
+	data := append([]byte{PackageEventTypeAccidentInfo, AccidentTypeProtectiveAlgorithm}, getSliceFromInt32(12)...)
+	data = append(data, 200, 0, 0, 0)
+	data = append(data, startSlice...)
+	data = append(data, 0, 0, 0, 0, 0, 0, 0, 0)
+
+	stateTime := start.Add(time.Hour)
+	assert.Nil(t, tracker.Apply(&NetworkPackage{HostId: 1, Data: DataPackage{Format: PackageFormatFullAccidentStates,
+		Time: GetUnixMicrosecondsFromTime(stateTime), Data: data}}))
+
+	open := tracker.GetOpenAccidents(1)
+	assert.Len(t, open, 1)
+	assert.Equal(t, uint32(200), open[0].ObjectId)
+
+	// Инцидент, отсутствующий в полном состоянии, завершается временем состояния
+	accidents := FilterAccidentsByType(tracker.GetAccidents(1, start, stateTime), AccidentTypeNwaLeave)
+	assert.Len(t, accidents, 1)
+	assert.Equal(t, uint32(100), accidents[0].ObjectId)
+	assert.True(t, stateTime.Equal(accidents[0].EndTime))
+	assert.True(t, accidents[0].IsEndMissing)
+
+	assert.NotNil(t, tracker.Apply(&NetworkPackage{HostId: 1, Data: DataPackage{Format: PackageFormatFullAccidentStates, Data: data[:10]}}))
+}
+
+func TestAccidentTrackerClosedHistory(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	key := ObjectAccidentKey{ObjectId: 100, AccidentId: -1}
+
+	tracker := NewAccidentTracker()
+	tracker.SetMaxClosedAccidents(2)
+
+	closeEvent := func(offset time.Duration) map[ObjectAccidentKey]*ObjectAccidentEventInfo {
+		return map[ObjectAccidentKey]*ObjectAccidentEventInfo{
+			key: {ObjectId: 100, AccidentType: AccidentTypeNwaLeave, AlgorithmId: -1,
+				StartTime: start.Add(offset), EndTime: start.Add(offset + time.Minute)}}
+	}
+
+	// Повтор события завершения не дублирует инцидент
+	tracker.ApplyEvents(1, closeEvent(0))
+	tracker.ApplyEvents(1, closeEvent(0))
+	assert.Len(t, tracker.GetAccidents(1, start, start.Add(time.Hour)), 1)
+
+	tracker.ApplyEvents(1, closeEvent(time.Minute*10))
+	tracker.ApplyEvents(1, closeEvent(time.Minute*20))
+
+	accidents := tracker.GetAccidents(1, start, start.Add(time.Hour))
+	assert.Len(t, accidents, 2)
+	assert.Equal(t, start.Add(time.Minute*10), accidents[0].StartTime)
+}
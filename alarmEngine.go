@@ -0,0 +1,187 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	AlarmRuleHigh         byte = 1 // значение выше Limit
+	AlarmRuleLow          byte = 2 // значение ниже Limit
+	AlarmRuleRateOfChange byte = 3 // модуль скорости изменения (единиц в секунду) выше Limit
+)
+
+// AlarmRule - правило формирования тревоги по значению датчика.
+// Тревога начинается, когда условие выполняется не менее MinDuration, и завершается,
+// когда значение вернулось за Limit с запасом Hysteresis.
+// Начало и завершение тревоги передаются как события отказа ObjectId/FailureId.
+type AlarmRule struct {
+	ObjectId    uint32
+	FailureId   uint32
+	Sensor      SensorKey
+	Kind        byte
+	Limit       float64
+	Hysteresis  float64
+	MinDuration time.Duration
+}
+
+type AlarmHandler func(event *ObjectFailureEventInfo)
+
+type alarmRuleState struct {
+	rule         AlarmRule
+	isActive     bool
+	pendingSince time.Time
+	lastValue    float64
+	lastTime     time.Time
+	hasLast      bool
+}
+
+// AlarmEngine формирует тревоги по значениям датчиков из пакетов измерений.
+// Если задан реестр датчиков, правила применяются к калиброванным значениям.
+type AlarmEngine struct {
+	mutex    sync.Mutex
+	registry *SensorRegistry
+	rules    map[SensorKey][]*alarmRuleState
+	onAlarm  AlarmHandler
+}
+
+func NewAlarmEngine(registry *SensorRegistry, onAlarm AlarmHandler) *AlarmEngine {
+	return &AlarmEngine{
+		registry: registry,
+		rules:    make(map[SensorKey][]*alarmRuleState),
+		onAlarm:  onAlarm}
+}
+
+func (engine *AlarmEngine) AddRule(rule AlarmRule) error {
+	switch rule.Kind {
+	case AlarmRuleHigh, AlarmRuleLow, AlarmRuleRateOfChange:
+	default:
+		return fmt.Errorf("unknown alarm rule kind %d", rule.Kind)
+	}
+
+	if rule.Hysteresis < 0 || rule.MinDuration < 0 {
+		return fmt.Errorf("alarm rule hysteresis and min duration should not be negative")
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	for _, states := range engine.rules {
+		for _, state := range states {
+			if state.rule.ObjectId == rule.ObjectId && state.rule.FailureId == rule.FailureId {
+				return fmt.Errorf("duplicate alarm rule for object %d failure %d", rule.ObjectId, rule.FailureId)
+			}
+		}
+	}
+
+	engine.rules[rule.Sensor] = append(engine.rules[rule.Sensor], &alarmRuleState{rule: rule})
+	return nil
+}
+
+// ApplyPackage проверяет правила по значениям датчиков пакета измерений.
+// Неопределенные значения не изменяют состояние тревог.
+func (engine *AlarmEngine) ApplyPackage(data *DataPackage) error {
+	measurements, err := data.GetMeasurements(engine.registry)
+	if err != nil {
+		return err
+	}
+
+	var events []*ObjectFailureEventInfo
+
+	engine.mutex.Lock()
+	for _, measurement := range measurements {
+		if measurement.IsUndefined {
+			continue
+		}
+
+		key := SensorKey{DeviceId: measurement.DeviceId, SensorIndex: measurement.SensorIndex}
+		for _, state := range engine.rules[key] {
+			if event := state.apply(measurement.Value, measurement.Time); event != nil {
+				events = append(events, event)
+			}
+		}
+	}
+	engine.mutex.Unlock()
+
+	if engine.onAlarm != nil {
+		for _, event := range events {
+			engine.onAlarm(event)
+		}
+	}
+	return nil
+}
+
+// GetActiveAlarms возвращает ключи активных тревог
+func (engine *AlarmEngine) GetActiveAlarms() []ObjectFailureKey {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	var result []ObjectFailureKey
+	for _, states := range engine.rules {
+		for _, state := range states {
+			if state.isActive {
+				result = append(result, ObjectFailureKey{ObjectId: state.rule.ObjectId, FailureId: state.rule.FailureId})
+			}
+		}
+	}
+	return result
+}
+
+func (state *alarmRuleState) apply(value float64, valueTime time.Time) *ObjectFailureEventInfo {
+	rule := &state.rule
+
+	var raised, cleared bool
+
+	switch rule.Kind {
+	case AlarmRuleHigh:
+		raised = value > rule.Limit
+		cleared = value <= rule.Limit-rule.Hysteresis
+	case AlarmRuleLow:
+		raised = value < rule.Limit
+		cleared = value >= rule.Limit+rule.Hysteresis
+	case AlarmRuleRateOfChange:
+		if !state.hasLast {
+			state.hasLast, state.lastValue, state.lastTime = true, value, valueTime
+			return nil
+		}
+
+		// Повторные и опоздавшие значения не меняют базу для вычисления скорости
+		elapsed := valueTime.Sub(state.lastTime).Seconds()
+		if elapsed <= 0 {
+			return nil
+		}
+
+		rate := math.Abs(value-state.lastValue) / elapsed
+		state.lastValue, state.lastTime = value, valueTime
+		raised = rate > rule.Limit
+		cleared = rate <= rule.Limit-rule.Hysteresis
+	}
+
+	if state.isActive {
+		if !cleared {
+			return nil
+		}
+		state.isActive = false
+		return &ObjectFailureEventInfo{ObjectId: rule.ObjectId, FailureId: rule.FailureId, IsStarted: false, EventTime: valueTime}
+	}
+
+	if !raised {
+		state.pendingSince = time.Time{}
+		return nil
+	}
+
+	if state.pendingSince.IsZero() {
+		state.pendingSince = valueTime
+	}
+
+	if valueTime.Sub(state.pendingSince) < rule.MinDuration {
+		return nil
+	}
+
+	state.isActive = true
+	startTime := state.pendingSince
+	state.pendingSince = time.Time{}
+	return &ObjectFailureEventInfo{ObjectId: rule.ObjectId, FailureId: rule.FailureId, IsStarted: true, EventTime: startTime}
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAlarmEngineLimits(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	var events []*ObjectFailureEventInfo
+	engine := NewAlarmEngine(nil, func(event *ObjectFailureEventInfo) {
+		events = append(events, event)
+	})
+
+	assert.Nil(t, engine.AddRule(AlarmRule{ObjectId: 100, FailureId: 1, Sensor: SensorKey{DeviceId: 10, SensorIndex: 0},
+		Kind: AlarmRuleHigh, Limit: 5, Hysteresis: 1, MinDuration: time.Second * 2}))
+	assert.Nil(t, engine.AddRule(AlarmRule{ObjectId: 100, FailureId: 2, Sensor: SensorKey{DeviceId: 10, SensorIndex: 1},
+		Kind: AlarmRuleLow, Limit: 1}))
+	assert.NotNil(t, engine.AddRule(AlarmRule{ObjectId: 100, FailureId: 2, Kind: AlarmRuleHigh}))
+	assert.NotNil(t, engine.AddRule(AlarmRule{ObjectId: 100, FailureId: 3}))
+
+	samples := []struct {
+		offset time.Duration
+		high   uint16
+		low    uint16
+	}{
+		{0, 6000, 2000},
+		{time.Second, 6000, 500}, // low: начало тревоги
+		{time.Second * 2, 6000, SystemUndefined16BitValue}, // high: начало тревоги после 2 секунд
+		{time.Second * 3, 4500, 1000},                      // high: в пределах гистерезиса, low: завершение
+		{time.Second * 4, 4000, 1000},                      // high: завершение
+	}
+
+	for _, sample := range samples {
+		assert.Nil(t, engine.ApplyPackage(makeMeasurePackage(10, start.Add(sample.offset), sample.high, sample.low)))
+	}
+
+	assert.Equal(t, []*ObjectFailureEventInfo{
+		{ObjectId: 100, FailureId: 2, IsStarted: true, EventTime: start.Add(time.Second)},
+		{ObjectId: 100, FailureId: 1, IsStarted: true, EventTime: start},
+		{ObjectId: 100, FailureId: 2, IsStarted: false, EventTime: start.Add(time.Second * 3)},
+		{ObjectId: 100, FailureId: 1, IsStarted: false, EventTime: start.Add(time.Second * 4)},
+	}, normalizeEventTimes(events))
+	assert.Empty(t, engine.GetActiveAlarms())
+}
+
+func TestAlarmEngineRateOfChange(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	var events []*ObjectFailureEventInfo
+	engine := NewAlarmEngine(nil, func(event *ObjectFailureEventInfo) {
+		events = append(events, event)
+	})
+
+	assert.Nil(t, engine.AddRule(AlarmRule{ObjectId: 200, FailureId: 1, Sensor: SensorKey{DeviceId: 10, SensorIndex: 0},
+		Kind: AlarmRuleRateOfChange, Limit: 2}))
+
+	assert.Nil(t, engine.ApplyPackage(makeMeasurePackage(10, start, 1000)))
+	assert.Nil(t, engine.ApplyPackage(makeMeasurePackage(10, start.Add(time.Second), 2000)))
+	assert.Empty(t, events)
+
+	assert.Nil(t, engine.ApplyPackage(makeMeasurePackage(10, start.Add(time.Second*2), 5000)))
+	assert.Len(t, events, 1)
+	assert.True(t, events[0].IsStarted)
+	assert.Equal(t, []ObjectFailureKey{{ObjectId: 200, FailureId: 1}}, engine.GetActiveAlarms())
+
+	assert.Nil(t, engine.ApplyPackage(makeMeasurePackage(10, start.Add(time.Second*3), 5000)))
+	assert.Len(t, events, 2)
+	assert.False(t, events[1].IsStarted)
+
+	// Опоздавшее значение не становится базой: скорость считается от значения в start+3s
+	assert.Nil(t, engine.ApplyPackage(makeMeasurePackage(10, start.Add(time.Millisecond*2500), 0)))
+	assert.Nil(t, engine.ApplyPackage(makeMeasurePackage(10, start.Add(time.Second*4), 5000)))
+	assert.Len(t, events, 2)
+}
+
+// normalizeEventTimes приводит время событий к UTC для сравнения
+func normalizeEventTimes(events []*ObjectFailureEventInfo) []*ObjectFailureEventInfo {
+	for _, event := range events {
+		event.EventTime = event.EventTime.UTC()
+	}
+	return events
+}
@@ -0,0 +1,323 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	AsyncLogPolicyDropNewest byte = 0 // при переполнении очереди отбрасывается новое сообщение
+	AsyncLogPolicyDropOldest byte = 1 // при переполнении очереди отбрасывается самое старое сообщение
+	AsyncLogPolicyBlock      byte = 2 // запись ожидает освобождения места в очереди
+)
+
+const (
+	DefaultAsyncLogQueueSize      = 4096
+	DefaultAsyncLogRateInterval   = time.Second
+	DefaultAsyncLogRepeatInterval = time.Second * 5
+)
+
+type AsyncLogOptions struct {
+	QueueSize int  // DefaultAsyncLogQueueSize если 0
+	Policy    byte // AsyncLogPolicy*
+	// Подряд идущие одинаковые сообщения заменяются сообщением "last message repeated N times".
+	// Сообщение о повторах выводится при получении другого сообщения, при Flush
+	// и не позднее RepeatInterval после первого повтора.
+	Deduplicate    bool
+	RepeatInterval time.Duration // DefaultAsyncLogRepeatInterval если 0
+	// Наибольшее количество сообщений с одинаковым уровнем и текстом за RateInterval, 0 - без ограничения.
+	// О подавленных сообщениях сообщается при первой записи после окончания интервала и при Flush.
+	RateLimit    int
+	RateInterval time.Duration // DefaultAsyncLogRateInterval если 0
+	Clock        Clock         // часы для интервалов ограничения частоты и вывода повторов
+}
+
+type asyncLogRateKey struct {
+	level   LogLevel
+	message string
+}
+
+// AsyncLogOutput передает сообщения в вывод output в отдельной горутине
+type AsyncLogOutput struct {
+	output  LogOutput
+	options AsyncLogOptions
+	queue   chan *LogEntry
+	dropped uint64
+	// Запросы Flush передаются отдельно от сообщений, чтобы не отбрасываться при переполнении очереди
+	flushes chan chan struct{}
+
+	rateMutex       sync.Mutex
+	rateWindowStart time.Time
+	rateCounts      map[asyncLogRateKey]int
+
+	// Используются только горутиной вывода
+	last        *LogEntry
+	repeats     int
+	repeatTimer Timer
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewAsyncLogOutput(output LogOutput, options AsyncLogOptions) *AsyncLogOutput {
+	if options.QueueSize <= 0 {
+		options.QueueSize = DefaultAsyncLogQueueSize
+	}
+	if options.RateInterval <= 0 {
+		options.RateInterval = DefaultAsyncLogRateInterval
+	}
+	if options.RepeatInterval <= 0 {
+		options.RepeatInterval = DefaultAsyncLogRepeatInterval
+	}
+
+	result := &AsyncLogOutput{
+		output:     output,
+		options:    options,
+		queue:      make(chan *LogEntry, options.QueueSize),
+		flushes:    make(chan chan struct{}),
+		rateCounts: make(map[asyncLogRateKey]int),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{})}
+
+	go result.run()
+	return result
+}
+
+func (output *AsyncLogOutput) MinLevel() LogLevel {
+	return output.output.MinLevel()
+}
+
+func (output *AsyncLogOutput) Write(entry *LogEntry) error {
+	summaries, allowed := output.applyRateLimit(entry)
+	for _, summary := range summaries {
+		output.enqueue(summary)
+	}
+	if allowed {
+		output.enqueue(entry)
+	}
+	return nil
+}
+
+// Dropped возвращает количество сообщений, отброшенных из-за переполнения очереди
+func (output *AsyncLogOutput) Dropped() uint64 {
+	return atomic.LoadUint64(&output.dropped)
+}
+
+// Flush ожидает вывода всех сообщений, помещенных в очередь до вызова
+func (output *AsyncLogOutput) Flush() {
+	for _, summary := range output.resetRateWindow(getClock(output.options.Clock).Now()) {
+		output.enqueue(summary)
+	}
+
+	flush := make(chan struct{})
+	select {
+	case output.flushes <- flush:
+	case <-output.done:
+		return
+	}
+
+	select {
+	case <-flush:
+	case <-output.done:
+	}
+}
+
+// Rotate выводит сообщения из очереди и начинает новый файл, если вывод поддерживает ротацию
+func (output *AsyncLogOutput) Rotate() error {
+	output.Flush()
+
+	if rotatable, ok := output.output.(RotatableLogOutput); ok {
+		return rotatable.Rotate()
+	}
+	return nil
+}
+
+// Close выводит сообщения из очереди и закрывает вывод
+func (output *AsyncLogOutput) Close() error {
+	var err error
+	output.closeOnce.Do(func() {
+		output.Flush()
+		close(output.stop)
+		<-output.done
+		err = output.output.Close()
+	})
+	return err
+}
+
+func (output *AsyncLogOutput) enqueue(entry *LogEntry) {
+	select {
+	case output.queue <- entry:
+		return
+	case <-output.done:
+		return
+	default:
+	}
+
+	switch output.options.Policy {
+	case AsyncLogPolicyDropOldest:
+		for {
+			select {
+			case <-output.queue:
+				atomic.AddUint64(&output.dropped, 1)
+			default:
+			}
+
+			select {
+			case output.queue <- entry:
+				return
+			case <-output.done:
+				return
+			default:
+			}
+		}
+
+	case AsyncLogPolicyBlock:
+		select {
+		case output.queue <- entry:
+		case <-output.done:
+		}
+
+	default:
+		atomic.AddUint64(&output.dropped, 1)
+	}
+}
+
+func (output *AsyncLogOutput) run() {
+	defer close(output.done)
+
+	for {
+		var repeatTimeout <-chan time.Time
+		if output.repeatTimer != nil {
+			repeatTimeout = output.repeatTimer.C()
+		}
+
+		select {
+		case entry := <-output.queue:
+			output.process(entry)
+		case flush := <-output.flushes:
+			output.flush(flush)
+		case <-repeatTimeout:
+			output.repeatTimer = nil
+			output.writeRepeats()
+		case <-output.stop:
+			// Сообщения, записанные после Flush в Close, выводятся до закрытия вывода
+			for len(output.queue) > 0 {
+				output.process(<-output.queue)
+			}
+			output.writeRepeats()
+			return
+		}
+	}
+}
+
+// flush выводит сообщения, находящиеся в очереди, и сообщение о повторах
+func (output *AsyncLogOutput) flush(done chan struct{}) {
+	// Очередь читает только горутина вывода, поэтому чтение непустой очереди не блокируется
+	for len(output.queue) > 0 {
+		output.process(<-output.queue)
+	}
+
+	output.writeRepeats()
+	output.last = nil
+	close(done)
+}
+
+func (output *AsyncLogOutput) process(entry *LogEntry) {
+	if output.options.Deduplicate && output.last != nil && isSameLogEntry(output.last, entry) {
+		output.repeats++
+		if output.repeatTimer == nil {
+			output.repeatTimer = getClock(output.options.Clock).NewTimer(output.options.RepeatInterval)
+		}
+		return
+	}
+
+	output.writeRepeats()
+	_ = output.output.Write(entry)
+	output.last = entry
+}
+
+func (output *AsyncLogOutput) writeRepeats() {
+	output.stopRepeatTimer()
+
+	if output.repeats == 0 {
+		return
+	}
+
+	_ = output.output.Write(&LogEntry{
+		Time:    getClock(output.options.Clock).Now(),
+		Level:   output.last.Level,
+		Message: fmt.Sprintf("last message repeated %d times", output.repeats)})
+	output.repeats = 0
+}
+
+func (output *AsyncLogOutput) stopRepeatTimer() {
+	if output.repeatTimer != nil {
+		output.repeatTimer.Stop()
+		output.repeatTimer = nil
+	}
+}
+
+// applyRateLimit учитывает сообщение в интервале ограничения частоты и возвращает сообщения о подавленных
+// в предыдущем интервале, если он истек, и признак того, что сообщение следует вывести.
+// Проверка и смена интервала выполняются под одной блокировкой.
+func (output *AsyncLogOutput) applyRateLimit(entry *LogEntry) ([]*LogEntry, bool) {
+	if output.options.RateLimit <= 0 {
+		return nil, true
+	}
+
+	now := getClock(output.options.Clock).Now()
+
+	output.rateMutex.Lock()
+	defer output.rateMutex.Unlock()
+
+	var summaries []*LogEntry
+	if output.rateWindowStart.IsZero() || now.Sub(output.rateWindowStart) >= output.options.RateInterval {
+		summaries = output.resetRateWindowLocked(now)
+	}
+
+	key := asyncLogRateKey{level: entry.Level, message: entry.Message}
+	output.rateCounts[key]++
+	return summaries, output.rateCounts[key] <= output.options.RateLimit
+}
+
+func (output *AsyncLogOutput) resetRateWindow(now time.Time) []*LogEntry {
+	if output.options.RateLimit <= 0 {
+		return nil
+	}
+
+	output.rateMutex.Lock()
+	defer output.rateMutex.Unlock()
+	return output.resetRateWindowLocked(now)
+}
+
+// resetRateWindowLocked начинает новый интервал ограничения частоты и возвращает сообщения
+// о подавленных в текущем интервале. Вызывается под rateMutex.
+func (output *AsyncLogOutput) resetRateWindowLocked(now time.Time) []*LogEntry {
+	var result []*LogEntry
+	for key, count := range output.rateCounts {
+		if suppressed := count - output.options.RateLimit; suppressed > 0 {
+			result = append(result, &LogEntry{
+				Time:    now,
+				Level:   key.level,
+				Message: fmt.Sprintf("message suppressed %d times by rate limit: %s", suppressed, key.message)})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Message < result[j].Message })
+
+	output.rateWindowStart = now
+	output.rateCounts = make(map[asyncLogRateKey]int)
+	return result
+}
+
+// isSameLogEntry сравнивает сообщения без учета времени
+func isSameLogEntry(first *LogEntry, second *LogEntry) bool {
+	return first.Level == second.Level &&
+		first.Caller == second.Caller &&
+		first.Message == second.Message &&
+		formatTextFields(first.Fields) == formatTextFields(second.Fields)
+}
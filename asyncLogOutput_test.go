@@ -0,0 +1,200 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingLogOutput ожидает разрешения перед выводом каждого сообщения
+type blockingLogOutput struct {
+	*MemoryLogOutput
+	release chan struct{}
+}
+
+func (output *blockingLogOutput) Write(entry *LogEntry) error {
+	<-output.release
+	return output.MemoryLogOutput.Write(entry)
+}
+
+func TestAsyncLogOutputDeduplicate(t *testing.T) {
+	memory := NewMemoryLogOutput(LogLevelTrace, 10)
+	clock := NewManualClock(time.Date(2020, 1, 1, 10, 0, 0, 0, time.Local))
+
+	logger := InitLogging([]LogOutput{memory}, WithClock(clock),
+		WithAsync(AsyncLogOptions{Deduplicate: true, Clock: clock}))
+
+	for i := 0; i < 5; i++ {
+		logger.Info("corrupt package", HostIdField(1))
+	}
+	logger.Info("corrupt package", HostIdField(2))
+	logger.Clear()
+
+	assert.Equal(t, []string{
+		"INFO: 10:00:00.000: corrupt package hostId=1",
+		"INFO: 10:00:00.000: last message repeated 4 times",
+		"INFO: 10:00:00.000: corrupt package hostId=2"}, memory.Lines())
+}
+
+func TestAsyncLogOutputStopDrainsQueue(t *testing.T) {
+	memory := NewMemoryLogOutput(LogLevelTrace, 10)
+	blocking := &blockingLogOutput{MemoryLogOutput: memory, release: make(chan struct{})}
+	clock := NewManualClock(time.Date(2020, 1, 1, 10, 0, 0, 0, time.Local))
+	output := NewAsyncLogOutput(blocking, AsyncLogOptions{Deduplicate: true, Clock: clock})
+
+	entry := &LogEntry{Time: clock.Now(), Level: LogLevelInfo, Message: "timeout"}
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, output.Write(entry))
+	}
+
+	// Остановка, пока сообщения еще в очереди
+	close(output.stop)
+	close(blocking.release)
+	<-output.done
+
+	assert.Equal(t, []string{
+		"INFO: 10:00:00.000: timeout",
+		"INFO: 10:00:00.000: last message repeated 2 times"}, memory.Lines())
+}
+
+func TestAsyncLogOutputRateLimit(t *testing.T) {
+	memory := NewMemoryLogOutput(LogLevelTrace, 10)
+	clock := NewManualClock(time.Date(2020, 1, 1, 10, 0, 0, 0, time.Local))
+
+	output := NewAsyncLogOutput(memory, AsyncLogOptions{RateLimit: 2, RateInterval: time.Second, Clock: clock})
+	logger := InitLogging([]LogOutput{output}, WithClock(clock))
+
+	for i := 0; i < 5; i++ {
+		logger.Error("crc error", Field("packageId", i))
+	}
+	logger.Warning("other")
+
+	clock.Advance(time.Second)
+	logger.Error("crc error", Field("packageId", 5))
+	output.Flush()
+
+	lines := memory.Lines()
+	assert.Len(t, lines, 5)
+	assert.True(t, strings.HasSuffix(lines[0], "crc error packageId=0"))
+	assert.True(t, strings.HasSuffix(lines[1], "crc error packageId=1"))
+	assert.True(t, strings.HasSuffix(lines[2], "other"))
+	assert.Equal(t, "ERROR: 10:00:01.000: message suppressed 3 times by rate limit: crc error", lines[3])
+	assert.True(t, strings.HasSuffix(lines[4], "crc error packageId=5"))
+
+	assert.Nil(t, output.Close())
+}
+
+func TestAsyncLogOutputOverflow(t *testing.T) {
+	memory := NewMemoryLogOutput(LogLevelTrace, 10)
+	blocking := &blockingLogOutput{MemoryLogOutput: memory, release: make(chan struct{})}
+
+	output := NewAsyncLogOutput(blocking, AsyncLogOptions{QueueSize: 2, Policy: AsyncLogPolicyDropOldest})
+	logger := InitLogging([]LogOutput{output})
+
+	// Первое сообщение ожидает вывода, в очереди остаются два последних
+	logger.Info("1")
+	for len(output.queue) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	logger.Info("2")
+	logger.Info("3")
+	logger.Info("4")
+	assert.Equal(t, uint64(1), output.Dropped())
+
+	close(blocking.release)
+	logger.Clear()
+
+	var messages []string
+	for _, entry := range memory.Entries() {
+		messages = append(messages, entry.Message)
+	}
+	assert.Equal(t, []string{"1", "3", "4"}, messages)
+}
+
+func TestAsyncLogOutputRepeatInterval(t *testing.T) {
+	memory := NewMemoryLogOutput(LogLevelTrace, 10)
+	clock := NewManualClock(time.Date(2020, 1, 1, 10, 0, 0, 0, time.Local))
+
+	output := NewAsyncLogOutput(memory, AsyncLogOptions{Deduplicate: true, RepeatInterval: time.Second, Clock: clock})
+	logger := InitLogging([]LogOutput{output}, WithClock(clock))
+
+	for i := 0; i < 3; i++ {
+		logger.Error("crc error")
+	}
+
+	// Сообщение о повторах выводится по истечении RepeatInterval без новых сообщений
+	assert.Eventually(t, func() bool {
+		clock.Advance(time.Second)
+		return len(memory.Lines()) == 2
+	}, time.Second*5, time.Millisecond)
+	assert.True(t, strings.HasSuffix(memory.Lines()[1], "last message repeated 2 times"))
+
+	assert.Nil(t, output.Close())
+	assert.Len(t, memory.Lines(), 2)
+}
+
+func TestAsyncLogOutputFlushOnOverflow(t *testing.T) {
+	memory := NewMemoryLogOutput(LogLevelTrace, 10)
+	output := NewAsyncLogOutput(memory, AsyncLogOptions{QueueSize: 1, Policy: AsyncLogPolicyDropOldest})
+
+	stop := make(chan struct{})
+	var writers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					_ = output.Write(&LogEntry{Level: LogLevelInfo, Message: "storm"})
+				}
+			}
+		}()
+	}
+
+	// Запрос Flush не отбрасывается при переполнении очереди
+	flushed := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			output.Flush()
+		}
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+	case <-time.After(time.Second * 10):
+		t.Fatal("flush is not completed")
+	}
+
+	close(stop)
+	writers.Wait()
+	assert.Nil(t, output.Close())
+}
+
+func TestAsyncRollFileLogging(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "test.log")
+
+	logger, err := InitRollFileLogging(fileName, false, WithAsync(AsyncLogOptions{Policy: AsyncLogPolicyBlock}))
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		logger.Info("message", Field("index", i))
+	}
+	logger.Clear()
+
+	lines := readLogLines(t, fileName)
+	assert.Len(t, lines, 100)
+	assert.True(t, strings.HasSuffix(lines[99], "message index=99"))
+}
@@ -0,0 +1,214 @@
+package core
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Timer - однократный таймер. Stop возвращает false, если таймер уже сработал или остановлен.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Clock - источник текущего времени и таймеров. Позволяет подменять время в тестах.
+// Часы задаются только при создании: полем Clock параметров компонента, для логгеров,
+// настраиваемых опциями LoggerOption, - опцией WithClock. Если часы не заданы, используется RealClock.
+type Clock interface {
+	Now() time.Time
+	After(duration time.Duration) <-chan time.Time
+	NewTimer(duration time.Duration) Timer
+	NewTicker(duration time.Duration) Ticker
+}
+
+type RealClock struct {
+}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (RealClock) After(duration time.Duration) <-chan time.Time {
+	return time.After(duration)
+}
+
+func (RealClock) NewTimer(duration time.Duration) Timer {
+	return &realTimer{timer: time.NewTimer(duration)}
+}
+
+func (RealClock) NewTicker(duration time.Duration) Ticker {
+	return &realTicker{ticker: time.NewTicker(duration)}
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (timer *realTimer) C() <-chan time.Time {
+	return timer.timer.C
+}
+
+func (timer *realTimer) Stop() bool {
+	return timer.timer.Stop()
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (ticker *realTicker) C() <-chan time.Time {
+	return ticker.ticker.C
+}
+
+func (ticker *realTicker) Stop() {
+	ticker.ticker.Stop()
+}
+
+// getClock возвращает clock или системные часы, если clock не задан
+func getClock(clock Clock) Clock {
+	if clock == nil {
+		return RealClock{}
+	}
+	return clock
+}
+
+// ManualClock - часы, время которых изменяется только вызовами Set и Advance.
+// Таймеры и тикеры срабатывают при переводе часов на время их срабатывания.
+type ManualClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	timers  []*manualTimer
+	tickers map[*manualTicker]bool
+}
+
+type manualTimer struct {
+	clock    *ManualClock
+	deadline time.Time
+	channel  chan time.Time
+}
+
+type manualTicker struct {
+	clock   *ManualClock
+	period  time.Duration
+	next    time.Time
+	channel chan time.Time
+}
+
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now, tickers: make(map[*manualTicker]bool)}
+}
+
+func (clock *ManualClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return clock.now
+}
+
+func (clock *ManualClock) After(duration time.Duration) <-chan time.Time {
+	return clock.NewTimer(duration).C()
+}
+
+func (clock *ManualClock) NewTimer(duration time.Duration) Timer {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	timer := &manualTimer{clock: clock, deadline: clock.now.Add(duration), channel: make(chan time.Time, 1)}
+	if duration <= 0 {
+		timer.channel <- clock.now
+		return timer
+	}
+
+	clock.timers = append(clock.timers, timer)
+	return timer
+}
+
+func (clock *ManualClock) NewTicker(duration time.Duration) Ticker {
+	if duration <= 0 {
+		panic("non-positive interval for ManualClock.NewTicker")
+	}
+
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	ticker := &manualTicker{clock: clock, period: duration, next: clock.now.Add(duration), channel: make(chan time.Time, 1)}
+	clock.tickers[ticker] = true
+	return ticker
+}
+
+// Advance переводит часы вперед на duration
+func (clock *ManualClock) Advance(duration time.Duration) {
+	clock.Set(clock.Now().Add(duration))
+}
+
+// Set устанавливает время часов. Перевод часов назад не вызывает срабатывания таймеров.
+func (clock *ManualClock) Set(now time.Time) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	clock.now = now
+
+	var waiting []*manualTimer
+	var fired []*manualTimer
+	for _, timer := range clock.timers {
+		if timer.deadline.After(now) {
+			waiting = append(waiting, timer)
+		} else {
+			fired = append(fired, timer)
+		}
+	}
+	clock.timers = waiting
+
+	sort.Slice(fired, func(i, j int) bool { return fired[i].deadline.Before(fired[j].deadline) })
+	for _, timer := range fired {
+		timer.channel <- timer.deadline
+	}
+
+	// Как и time.Ticker, тикер не накапливает пропущенные срабатывания
+	for ticker := range clock.tickers {
+		if ticker.next.After(now) {
+			continue
+		}
+
+		select {
+		case ticker.channel <- ticker.next:
+		default:
+		}
+
+		for !ticker.next.After(now) {
+			ticker.next = ticker.next.Add(ticker.period)
+		}
+	}
+}
+
+func (timer *manualTimer) C() <-chan time.Time {
+	return timer.channel
+}
+
+func (timer *manualTimer) Stop() bool {
+	timer.clock.mutex.Lock()
+	defer timer.clock.mutex.Unlock()
+
+	for i, waiting := range timer.clock.timers {
+		if waiting == timer {
+			timer.clock.timers = append(timer.clock.timers[:i], timer.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (ticker *manualTicker) C() <-chan time.Time {
+	return ticker.channel
+}
+
+func (ticker *manualTicker) Stop() {
+	ticker.clock.mutex.Lock()
+	defer ticker.clock.mutex.Unlock()
+	delete(ticker.clock.tickers, ticker)
+}
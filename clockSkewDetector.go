@@ -0,0 +1,266 @@
+package core
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultClockSkewWindow     = 100
+	DefaultClockSkewMinSamples = 5
+	DefaultClockSkewThreshold  = time.Second * 5
+)
+
+type ClockSkewOptions struct {
+	WindowSize   int           // количество последних пакетов для оценки, DefaultClockSkewWindow если 0
+	MinSamples   int           // минимальное количество пакетов для оценки, DefaultClockSkewMinSamples если 0
+	Threshold    time.Duration // допустимое расхождение часов, DefaultClockSkewThreshold если 0
+	CorrectTimes bool          // корректировать время хостов с расхождением выше Threshold
+	Clock        Clock         // часы для определения времени получения пакетов в Apply
+}
+
+// HostClockSkew - оценка расхождения часов хоста.
+// Skew - медиана разности времени получения и времени пакета, положительное значение - часы хоста отстают.
+type HostClockSkew struct {
+	HostId     int32
+	Skew       time.Duration
+	Samples    int
+	IsDrifting bool
+	UpdateTime time.Time
+}
+
+// CorrectedTime - время хоста с примененной поправкой
+type CorrectedTime struct {
+	Original   time.Time
+	Corrected  time.Time
+	Correction time.Duration
+}
+
+// CorrectedEvents - события пакета хоста с примененной поправкой времени
+type CorrectedEvents struct {
+	Original   *PackageEvents // события с исходным временем хоста
+	Corrected  *PackageEvents // копия событий с исправленным временем
+	Correction time.Duration
+}
+
+type ClockDriftHandler func(skew HostClockSkew)
+
+type hostClockSamples struct {
+	samples []time.Duration
+	next    int
+	skew    HostClockSkew
+}
+
+// ClockSkewDetector оценивает расхождение часов хостов с локальными часами по времени пакетов.
+// Оценка устойчива к единичным задержкам доставки, так как используется медиана по окну.
+type ClockSkewDetector struct {
+	mutex    sync.RWMutex
+	options  ClockSkewOptions
+	hosts    map[int32]*hostClockSamples
+	onChange ClockDriftHandler
+}
+
+func NewClockSkewDetector(options ClockSkewOptions, onChange ClockDriftHandler) *ClockSkewDetector {
+	if options.WindowSize <= 0 {
+		options.WindowSize = DefaultClockSkewWindow
+	}
+	if options.MinSamples <= 0 {
+		options.MinSamples = DefaultClockSkewMinSamples
+	}
+	if options.MinSamples > options.WindowSize {
+		options.MinSamples = options.WindowSize
+	}
+	if options.Threshold <= 0 {
+		options.Threshold = DefaultClockSkewThreshold
+	}
+
+	return &ClockSkewDetector{
+		options:  options,
+		hosts:    make(map[int32]*hostClockSamples),
+		onChange: onChange}
+}
+
+func (detector *ClockSkewDetector) Apply(networkPackage *NetworkPackage) {
+	detector.ApplyAt(networkPackage, getClock(detector.options.Clock).Now())
+}
+
+// ApplyAt учитывает сетевой пакет, полученный в момент receiveTime. Пакеты без времени не учитываются.
+func (detector *ClockSkewDetector) ApplyAt(networkPackage *NetworkPackage, receiveTime time.Time) {
+	if networkPackage.Data.Time == 0 {
+		return
+	}
+	detector.AddSample(networkPackage.HostId, networkPackage.Data.GetPackageTime(), receiveTime)
+}
+
+func (detector *ClockSkewDetector) AddSample(hostId int32, packageTime time.Time, receiveTime time.Time) {
+	detector.mutex.Lock()
+
+	host, ok := detector.hosts[hostId]
+	if !ok {
+		host = &hostClockSamples{skew: HostClockSkew{HostId: hostId}}
+		detector.hosts[hostId] = host
+	}
+
+	sample := receiveTime.Sub(packageTime)
+	if len(host.samples) < detector.options.WindowSize {
+		host.samples = append(host.samples, sample)
+	} else {
+		host.samples[host.next] = sample
+		host.next = (host.next + 1) % detector.options.WindowSize
+	}
+
+	wasDrifting := host.skew.IsDrifting
+	host.skew.Skew = getMedianDuration(host.samples)
+	host.skew.Samples = len(host.samples)
+	host.skew.UpdateTime = receiveTime
+	host.skew.IsDrifting = host.skew.Samples >= detector.options.MinSamples &&
+		absDuration(host.skew.Skew) > detector.options.Threshold
+
+	skew := host.skew
+	detector.mutex.Unlock()
+
+	if detector.onChange != nil && skew.IsDrifting != wasDrifting {
+		detector.onChange(skew)
+	}
+}
+
+func (detector *ClockSkewDetector) GetSkew(hostId int32) (HostClockSkew, bool) {
+	detector.mutex.RLock()
+	defer detector.mutex.RUnlock()
+
+	host, ok := detector.hosts[hostId]
+	if !ok {
+		return HostClockSkew{HostId: hostId}, false
+	}
+	return host.skew, true
+}
+
+func (detector *ClockSkewDetector) IsDrifting(hostId int32) bool {
+	skew, _ := detector.GetSkew(hostId)
+	return skew.IsDrifting
+}
+
+func (detector *ClockSkewDetector) GetDriftingHosts() []int32 {
+	detector.mutex.RLock()
+	defer detector.mutex.RUnlock()
+
+	var result []int32
+	for hostId, host := range detector.hosts {
+		if host.skew.IsDrifting {
+			result = append(result, hostId)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// CorrectTime возвращает время хоста, приведенное к локальным часам.
+// Поправка применяется только при включенной CorrectTimes для хостов с расхождением выше Threshold.
+func (detector *ClockSkewDetector) CorrectTime(hostId int32, hostTime time.Time) CorrectedTime {
+	result := CorrectedTime{Original: hostTime, Corrected: hostTime}
+
+	if hostTime.IsZero() {
+		return result
+	}
+
+	result.Correction = detector.getCorrection(hostId)
+	result.Corrected = hostTime.Add(result.Correction)
+	return result
+}
+
+// CorrectEvents возвращает копию событий пакета со временем, приведенным к локальным часам, вместе с исходными
+// событиями и примененной поправкой. Исходные события не изменяются, так как могут использоваться другими
+// получателями пакета. При нулевой поправке Corrected совпадает с Original.
+func (detector *ClockSkewDetector) CorrectEvents(hostId int32, events *PackageEvents) CorrectedEvents {
+	result := CorrectedEvents{Original: events, Corrected: events}
+
+	result.Correction = detector.getCorrection(hostId)
+	if result.Correction == 0 || events == nil {
+		return result
+	}
+
+	correct := func(value time.Time) time.Time {
+		if value.IsZero() {
+			return value
+		}
+		return value.Add(result.Correction)
+	}
+
+	corrected := &PackageEvents{
+		ObjectStates:               make(map[uint32]uint16, len(events.ObjectStates)),
+		ObjectFailuresChangeState:  make(map[ObjectFailureKey]*ObjectFailureEventInfo, len(events.ObjectFailuresChangeState)),
+		ObjectAccidentsChangeState: make(map[ObjectAccidentKey]*ObjectAccidentEventInfo, len(events.ObjectAccidentsChangeState)),
+		ObjectFpChangeState:        make(map[uint32]*ObjectFpEventInfo, len(events.ObjectFpChangeState)),
+		ObjectNwaChangeState:       make(map[uint32]*ObjectNwaStateLeaveEventInfo, len(events.ObjectNwaChangeState)),
+		ObjectNwaStateLeaveEnter:   make(map[uint32]*ObjectNwaStateChangeEventInfo, len(events.ObjectNwaStateLeaveEnter)),
+		DevicesNoConnection:        make(map[DeviceId]*DeviceNoConnectionEventInfo, len(events.DevicesNoConnection))}
+
+	for key, value := range events.ObjectStates {
+		corrected.ObjectStates[key] = value
+	}
+	for key, event := range events.ObjectFailuresChangeState {
+		copied := *event
+		copied.EventTime = correct(event.EventTime)
+		corrected.ObjectFailuresChangeState[key] = &copied
+	}
+	for key, event := range events.ObjectAccidentsChangeState {
+		copied := *event
+		copied.StartTime = correct(event.StartTime)
+		copied.EndTime = correct(event.EndTime)
+		corrected.ObjectAccidentsChangeState[key] = &copied
+	}
+	for key, event := range events.ObjectFpChangeState {
+		copied := *event
+		copied.EventTime = correct(event.EventTime)
+		corrected.ObjectFpChangeState[key] = &copied
+	}
+	for key, event := range events.ObjectNwaChangeState {
+		copied := *event
+		copied.EventTime = correct(event.EventTime)
+		corrected.ObjectNwaChangeState[key] = &copied
+	}
+	for key, event := range events.ObjectNwaStateLeaveEnter {
+		copied := *event
+		copied.EventTime = correct(event.EventTime)
+		corrected.ObjectNwaStateLeaveEnter[key] = &copied
+	}
+	for key, event := range events.DevicesNoConnection {
+		copied := *event
+		copied.EventTime = correct(event.EventTime)
+		corrected.DevicesNoConnection[key] = &copied
+	}
+
+	result.Corrected = corrected
+	return result
+}
+
+func (detector *ClockSkewDetector) getCorrection(hostId int32) time.Duration {
+	if !detector.options.CorrectTimes {
+		return 0
+	}
+
+	skew, _ := detector.GetSkew(hostId)
+	if !skew.IsDrifting {
+		return 0
+	}
+	return skew.Skew
+}
+
+func getMedianDuration(values []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+func absDuration(value time.Duration) time.Duration {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
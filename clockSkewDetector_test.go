@@ -0,0 +1,69 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestClockSkewDetector(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	var changes []HostClockSkew
+	detector := NewClockSkewDetector(ClockSkewOptions{WindowSize: 5, MinSamples: 3, Threshold: time.Second, CorrectTimes: true},
+		func(skew HostClockSkew) {
+			changes = append(changes, skew)
+		})
+
+	// Часы хоста 1 отстают на 10 секунд, одна задержка доставки не влияет на оценку
+	delays := []time.Duration{0, time.Millisecond * 100, time.Minute, time.Millisecond * 50, 0}
+	for i, delay := range delays {
+		packageTime := start.Add(time.Second * time.Duration(i))
+		detector.AddSample(1, packageTime, packageTime.Add(time.Second*10+delay))
+		detector.AddSample(2, packageTime, packageTime.Add(delay/100))
+	}
+
+	skew, ok := detector.GetSkew(1)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second*10+time.Millisecond*50, skew.Skew)
+	assert.Equal(t, 5, skew.Samples)
+	assert.True(t, detector.IsDrifting(1))
+	assert.False(t, detector.IsDrifting(2))
+	assert.Equal(t, []int32{1}, detector.GetDriftingHosts())
+
+	assert.Len(t, changes, 1)
+	assert.Equal(t, int32(1), changes[0].HostId)
+	assert.Equal(t, 3, changes[0].Samples)
+
+	corrected := detector.CorrectTime(1, start)
+	assert.Equal(t, start, corrected.Original)
+	assert.Equal(t, skew.Skew, corrected.Correction)
+	assert.Equal(t, start.Add(skew.Skew), corrected.Corrected)
+
+	corrected = detector.CorrectTime(2, start)
+	assert.Equal(t, time.Duration(0), corrected.Correction)
+	assert.Equal(t, start, corrected.Corrected)
+
+	events := &PackageEvents{ObjectFailuresChangeState: map[ObjectFailureKey]*ObjectFailureEventInfo{
+		{ObjectId: 100, FailureId: 1}: {ObjectId: 100, FailureId: 1, IsStarted: true, EventTime: start}}}
+	correctedEvents := detector.CorrectEvents(1, events)
+	assert.Equal(t, skew.Skew, correctedEvents.Correction)
+	assert.Equal(t, start.Add(skew.Skew), correctedEvents.Corrected.ObjectFailuresChangeState[ObjectFailureKey{100, 1}].EventTime)
+
+	// Исходные события не изменяются
+	assert.True(t, events == correctedEvents.Original)
+	assert.Equal(t, start, events.ObjectFailuresChangeState[ObjectFailureKey{100, 1}].EventTime)
+
+	correctedEvents = detector.CorrectEvents(2, events)
+	assert.Equal(t, time.Duration(0), correctedEvents.Correction)
+	assert.True(t, events == correctedEvents.Corrected)
+
+	// Окно заполняется пакетами с верным временем
+	for i := 0; i < 5; i++ {
+		packageTime := start.Add(time.Minute + time.Second*time.Duration(i))
+		detector.ApplyAt(&NetworkPackage{HostId: 1, Data: DataPackage{Time: uint64(GetUnixMicrosecondsFromTime(packageTime))}}, packageTime)
+	}
+	assert.False(t, detector.IsDrifting(1))
+	assert.Len(t, changes, 2)
+	assert.Empty(t, detector.GetDriftingHosts())
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+
+	after := clock.After(time.Second * 2)
+	ticker := clock.NewTicker(time.Second)
+
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Second), clock.Now())
+	assert.Equal(t, start.Add(time.Second), <-ticker.C())
+	assert.Len(t, after, 0)
+
+	// Пропущенные срабатывания тикера не накапливаются
+	clock.Advance(time.Second * 3)
+	assert.Equal(t, start.Add(time.Second*2), <-after)
+	assert.Equal(t, start.Add(time.Second*2), <-ticker.C())
+	assert.Len(t, ticker.C(), 0)
+
+	ticker.Stop()
+	clock.Advance(time.Second * 10)
+	assert.Len(t, ticker.C(), 0)
+
+	assert.Len(t, clock.After(0), 1)
+}
+
+func TestManualClockTimer(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+
+	timer := clock.NewTimer(time.Second)
+	stopped := clock.NewTimer(time.Second)
+	assert.True(t, stopped.Stop())
+
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Second), <-timer.C())
+	assert.False(t, timer.Stop())
+	assert.Len(t, stopped.C(), 0)
+
+	accident := &ObjectAccidentEventInfo{StartTime: start.Add(-time.Minute)}
+	assert.Equal(t, time.Minute+time.Second, accident.Duration(clock.Now()))
+}
+
+func TestDeviceAvailabilityTrackerClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+
+	tracker := NewDeviceAvailabilityTracker(DeviceAvailabilityOptions{Clock: clock}, nil)
+
+	assert.Nil(t, tracker.Apply(&NetworkPackage{HostId: 1, Data: DataPackage{DeviceId: 10, Format: PackageFormatHeartbeat}}))
+	assert.Equal(t, start, tracker.GetHistory(1, 10)[0].Time)
+}
+
+func TestLoggerClock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "test.log")
+	clock := NewManualClock(time.Date(2020, 1, 1, 10, 20, 30, 0, time.Local))
+
+	logger, err := InitRollFileLogging(fileName, false, WithClock(clock))
+	assert.Nil(t, err)
+
+	logger.Info("message")
+	logger.Clear()
+
+	content, err := ioutil.ReadFile(fileName)
+	assert.Nil(t, err)
+	assert.Equal(t, "INFO:    2020/01/01 10:20:30.000: message\n", string(content))
+}
@@ -0,0 +1,288 @@
+package core
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	CsvEventObjectState  = "object_state"
+	CsvEventFailure      = "failure"
+	CsvEventAccident     = "accident"
+	CsvEventFp           = "fp"
+	CsvEventNwaLeave     = "nwa_leave"
+	CsvEventNwaState     = "nwa_state"
+	CsvEventNoConnection = "no_connection"
+)
+
+type CsvExportOptions struct {
+	Location   *time.Location // часовой пояс для времени, GetDisplayLocation() если nil
+	Delimiter  rune           // разделитель полей, ',' если 0
+	TimeFormat string         // формат времени, time.RFC3339Nano если пусто
+}
+
+type csvExportWriter struct {
+	writer        *csv.Writer
+	options       CsvExportOptions
+	header        []string
+	headerWritten bool
+}
+
+func newCsvExportWriter(writer io.Writer, options CsvExportOptions, header []string) csvExportWriter {
+	if options.Location == nil {
+		options.Location = GetDisplayLocation()
+	}
+	if options.TimeFormat == "" {
+		options.TimeFormat = time.RFC3339Nano
+	}
+
+	result := csvExportWriter{writer: csv.NewWriter(writer), options: options, header: header}
+	if options.Delimiter != 0 {
+		result.writer.Comma = options.Delimiter
+	}
+	return result
+}
+
+func (export *csvExportWriter) write(record []string) error {
+	if !export.headerWritten {
+		if err := export.writer.Write(export.header); err != nil {
+			return err
+		}
+		export.headerWritten = true
+	}
+	return export.writer.Write(record)
+}
+
+func (export *csvExportWriter) formatTime(value time.Time) string {
+	if value.IsZero() {
+		return ""
+	}
+	return value.In(export.options.Location).Format(export.options.TimeFormat)
+}
+
+// Flush записывает буферизованные строки и возвращает ошибку записи, если она была
+func (export *csvExportWriter) Flush() error {
+	export.writer.Flush()
+	return export.writer.Error()
+}
+
+// MeasurementCsvWriter записывает значения датчиков из пакетов измерений, по строке на датчик
+type MeasurementCsvWriter struct {
+	csvExportWriter
+}
+
+func NewMeasurementCsvWriter(writer io.Writer, options CsvExportOptions) *MeasurementCsvWriter {
+	return &MeasurementCsvWriter{
+		newCsvExportWriter(writer, options, []string{"time", "device", "sensor", "value", "undefined"})}
+}
+
+// WritePackage записывает значения датчиков пакета. Пакеты специального устройства хоста
+// не относятся к измерениям полевых устройств и пропускаются.
+func (export *MeasurementCsvWriter) WritePackage(data *DataPackage) error {
+	if data.Format != PackageFormatData {
+		return fmt.Errorf("expected data package format")
+	}
+	if data.IsHostPackage() {
+		return nil
+	}
+	if data.IsCompressed() {
+		return fmt.Errorf("compressed data package is not supported")
+	}
+
+	converter, err := GetDataConverterFunction(data.BitsPerSensor)
+	if err != nil {
+		return err
+	}
+
+	packageTime := export.formatTime(data.GetPackageTime())
+	deviceId := strconv.FormatInt(int64(data.DeviceId), 10)
+
+	for sensorId := uint16(0); sensorId < data.SensorCount; sensorId++ {
+		value := converter(data.Data, sensorId)
+
+		record := []string{packageTime, deviceId, strconv.Itoa(int(sensorId)), "", "1"}
+		if !IsNaN(value) {
+			record[3] = strconv.FormatFloat(float64(value), 'f', -1, 32)
+			record[4] = "0"
+		}
+
+		if err = export.write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EventCsvWriter записывает события из пакетов событий, по строке на событие.
+// Колонки id, value, started и end_time заполняются в зависимости от типа события.
+// Для потери связи с устройством в колонке object - ид. устройства, в id - ид. канала связи.
+type EventCsvWriter struct {
+	csvExportWriter
+}
+
+func NewEventCsvWriter(writer io.Writer, options CsvExportOptions) *EventCsvWriter {
+	return &EventCsvWriter{
+		newCsvExportWriter(writer, options,
+			[]string{"time", "host", "event", "object", "id", "value", "started", "end_time"})}
+}
+
+// WritePackage записывает события пакета. Пакеты без событий игнорируются.
+func (export *EventCsvWriter) WritePackage(networkPackage *NetworkPackage) error {
+	switch networkPackage.Data.Format {
+	case PackageFormatEvents, PackageFormatChangeObjectStates, PackageFormatChangeFailureStates:
+	default:
+		return nil
+	}
+
+	events, err := networkPackage.Data.ParseEventsPackage()
+	if err != nil {
+		return err
+	}
+
+	return export.WriteEvents(networkPackage.HostId, networkPackage.Data.GetPackageTime(), events)
+}
+
+// WriteEvents записывает события, упорядоченные по типу и идентификатору объекта.
+// Время пакета используется для состояний объектов, не имеющих собственного времени.
+func (export *EventCsvWriter) WriteEvents(hostId int32, packageTime time.Time, events *PackageEvents) error {
+	host := strconv.FormatInt(int64(hostId), 10)
+
+	var objectIds []uint32
+	for objectId := range events.ObjectStates {
+		objectIds = append(objectIds, objectId)
+	}
+	sortUint32(objectIds)
+	for _, objectId := range objectIds {
+		err := export.writeEvent(packageTime, host, CsvEventObjectState, objectId, "",
+			strconv.Itoa(int(events.ObjectStates[objectId])), "", time.Time{})
+		if err != nil {
+			return err
+		}
+	}
+
+	var failureKeys []ObjectFailureKey
+	for key := range events.ObjectFailuresChangeState {
+		failureKeys = append(failureKeys, key)
+	}
+	sort.Slice(failureKeys, func(i, j int) bool {
+		if failureKeys[i].ObjectId != failureKeys[j].ObjectId {
+			return failureKeys[i].ObjectId < failureKeys[j].ObjectId
+		}
+		return failureKeys[i].FailureId < failureKeys[j].FailureId
+	})
+	for _, key := range failureKeys {
+		event := events.ObjectFailuresChangeState[key]
+		err := export.writeEvent(event.EventTime, host, CsvEventFailure, event.ObjectId,
+			strconv.FormatUint(uint64(event.FailureId), 10), "", formatCsvBool(event.IsStarted), time.Time{})
+		if err != nil {
+			return err
+		}
+	}
+
+	var accidentKeys []ObjectAccidentKey
+	for key := range events.ObjectAccidentsChangeState {
+		accidentKeys = append(accidentKeys, key)
+	}
+	sort.Slice(accidentKeys, func(i, j int) bool {
+		if accidentKeys[i].ObjectId != accidentKeys[j].ObjectId {
+			return accidentKeys[i].ObjectId < accidentKeys[j].ObjectId
+		}
+		return accidentKeys[i].AccidentId < accidentKeys[j].AccidentId
+	})
+	for _, key := range accidentKeys {
+		event := events.ObjectAccidentsChangeState[key]
+		err := export.writeEvent(event.StartTime, host, CsvEventAccident, event.ObjectId,
+			strconv.FormatInt(int64(event.AlgorithmId), 10), strconv.Itoa(int(event.AccidentType)), "", event.EndTime)
+		if err != nil {
+			return err
+		}
+	}
+
+	objectIds = objectIds[:0]
+	for objectId := range events.ObjectFpChangeState {
+		objectIds = append(objectIds, objectId)
+	}
+	sortUint32(objectIds)
+	for _, objectId := range objectIds {
+		event := events.ObjectFpChangeState[objectId]
+		err := export.writeEvent(event.EventTime, host, CsvEventFp, event.ObjectId,
+			strconv.FormatUint(uint64(event.AlgorithmId), 10), strconv.FormatInt(int64(event.StepIndex), 10), "", time.Time{})
+		if err != nil {
+			return err
+		}
+	}
+
+	objectIds = objectIds[:0]
+	for objectId := range events.ObjectNwaChangeState {
+		objectIds = append(objectIds, objectId)
+	}
+	sortUint32(objectIds)
+	for _, objectId := range objectIds {
+		event := events.ObjectNwaChangeState[objectId]
+		err := export.writeEvent(event.EventTime, host, CsvEventNwaLeave, event.ObjectId,
+			strconv.FormatUint(uint64(event.AlgorithmId), 10), strconv.FormatInt(int64(event.StateId), 10),
+			formatCsvBool(event.IsStarted), time.Time{})
+		if err != nil {
+			return err
+		}
+	}
+
+	objectIds = objectIds[:0]
+	for objectId := range events.ObjectNwaStateLeaveEnter {
+		objectIds = append(objectIds, objectId)
+	}
+	sortUint32(objectIds)
+	for _, objectId := range objectIds {
+		event := events.ObjectNwaStateLeaveEnter[objectId]
+		err := export.writeEvent(event.EventTime, host, CsvEventNwaState, event.ObjectId,
+			"", strconv.FormatInt(int64(event.NwaStateId), 10), "", time.Time{})
+		if err != nil {
+			return err
+		}
+	}
+
+	var deviceIds []DeviceId
+	for deviceId := range events.DevicesNoConnection {
+		deviceIds = append(deviceIds, deviceId)
+	}
+	sort.Slice(deviceIds, func(i, j int) bool { return deviceIds[i] < deviceIds[j] })
+	for _, deviceId := range deviceIds {
+		event := events.DevicesNoConnection[deviceId]
+		err := export.writeEvent(event.EventTime, host, CsvEventNoConnection, uint32(event.DeviceId),
+			strconv.FormatUint(uint64(event.ConnectionId), 10), "", formatCsvBool(event.IsStarted), time.Time{})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (export *EventCsvWriter) writeEvent(eventTime time.Time, host string, event string, objectId uint32,
+	id string, value string, started string, endTime time.Time) error {
+
+	return export.write([]string{
+		export.formatTime(eventTime),
+		host,
+		event,
+		strconv.FormatUint(uint64(objectId), 10),
+		id,
+		value,
+		started,
+		export.formatTime(endTime)})
+}
+
+func formatCsvBool(value bool) string {
+	if value {
+		return "1"
+	}
+	return "0"
+}
+
+func sortUint32(values []uint32) {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMeasurementCsvWriter(t *testing.T) {
+	packageTime := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	location := time.FixedZone("MSK", 3*60*60)
+
+	var buffer bytes.Buffer
+	export := NewMeasurementCsvWriter(&buffer, CsvExportOptions{Location: location, Delimiter: ';'})
+
+	assert.Nil(t, export.WritePackage(makeMeasurePackage(10, packageTime, 1500, SystemUndefined16BitValue)))
+	assert.NotNil(t, export.WritePackage(&DataPackage{Format: PackageFormatHeartbeat}))
+
+	// Пакет хоста не попадает в выгрузку измерений
+	hostDevice, _ := NewSpecialDeviceId(3)
+	assert.Nil(t, export.WritePackage(makeMeasurePackage(hostDevice, packageTime, 1000)))
+	assert.Nil(t, export.Flush())
+
+	assert.Equal(t,
+		"time;device;sensor;value;undefined\n"+
+			"2020-01-01T13:00:00+03:00;10;0;1.5;0\n"+
+			"2020-01-01T13:00:00+03:00;10;1;;1\n",
+		buffer.String())
+}
+
+func TestEventCsvWriter(t *testing.T) {
+	eventTime := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	var buffer bytes.Buffer
+	export := NewEventCsvWriter(&buffer, CsvExportOptions{Location: time.UTC})
+
+	err := export.WriteEvents(1, eventTime, &PackageEvents{
+		ObjectStates: map[uint32]uint16{200: 3, 100: 1},
+		ObjectFailuresChangeState: map[ObjectFailureKey]*ObjectFailureEventInfo{
+			{ObjectId: 100, FailureId: 5}: {ObjectId: 100, FailureId: 5, IsStarted: true, EventTime: eventTime}},
+		ObjectAccidentsChangeState: map[ObjectAccidentKey]*ObjectAccidentEventInfo{
+			{ObjectId: 100, AccidentId: -1}: {ObjectId: 100, AccidentType: AccidentTypeNwaLeave, AlgorithmId: -1,
+				StartTime: eventTime, EndTime: eventTime.Add(time.Minute)}},
+		ObjectFpChangeState: map[uint32]*ObjectFpEventInfo{
+			100: {ObjectId: 100, AlgorithmId: 4, StepIndex: 2, EventTime: eventTime}},
+		ObjectNwaChangeState: map[uint32]*ObjectNwaStateLeaveEventInfo{
+			100: {ObjectId: 100, AlgorithmId: 4, StateId: 23, IsStarted: true, EventTime: eventTime}},
+		ObjectNwaStateLeaveEnter: map[uint32]*ObjectNwaStateChangeEventInfo{
+			100: {ObjectId: 100, NwaStateId: 23, EventTime: eventTime}},
+		DevicesNoConnection: map[DeviceId]*DeviceNoConnectionEventInfo{
+			10: {DeviceId: 10, ConnectionId: 2, IsStarted: true, EventTime: eventTime}},
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, export.Flush())
+
+	assert.Equal(t,
+		"time,host,event,object,id,value,started,end_time\n"+
+			"2020-01-01T10:00:00Z,1,object_state,100,,1,,\n"+
+			"2020-01-01T10:00:00Z,1,object_state,200,,3,,\n"+
+			"2020-01-01T10:00:00Z,1,failure,100,5,,1,\n"+
+			"2020-01-01T10:00:00Z,1,accident,100,-1,1,,2020-01-01T10:01:00Z\n"+
+			"2020-01-01T10:00:00Z,1,fp,100,4,2,,\n"+
+			"2020-01-01T10:00:00Z,1,nwa_leave,100,4,23,1,\n"+
+			"2020-01-01T10:00:00Z,1,nwa_state,100,,23,,\n"+
+			"2020-01-01T10:00:00Z,1,no_connection,10,2,,1,\n",
+		buffer.String())
+}
@@ -28,3 +28,8 @@ const (
 	PackageEventTypeAccidentInfo                  byte = 7
 	PackageEventTypeObjectState                   byte = 8
 )
+
+const (
+	AccidentTypeNwaLeave            byte = 1 // Выход из АНР
+	AccidentTypeProtectiveAlgorithm byte = 2 // Срабатывание АП
+)
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/deckarep/golang-set"
@@ -13,7 +14,7 @@ import (
 
 type DataPackage struct {
 	Time          uint64
-	DeviceId      int32
+	DeviceId      DeviceId
 	SensorCount   uint16
 	BitsPerSensor byte
 	Format        byte
@@ -80,7 +81,19 @@ func (data *DataPackage) String() string {
 	return fmt.Sprintf("DevId=%d,Format=%d,Time=%s",
 		data.DeviceId,
 		data.Format,
-		data.GetPackageTime().Format(time.RFC3339Nano))
+		FormatPackageTime(data.Time, time.RFC3339Nano, nil))
+}
+
+// MarshalJSON добавляет к полям пакета время пакета в часовом поясе отображения
+func (data DataPackage) MarshalJSON() ([]byte, error) {
+	type dataPackageFields DataPackage
+
+	return json.Marshal(&struct {
+		dataPackageFields
+		PackageTime string
+	}{
+		dataPackageFields: dataPackageFields(data),
+		PackageTime:       FormatPackageTime(data.Time, time.RFC3339Nano, nil)})
 }
 
 func (data *DataPackage) Read(reader *bytes.Reader) error {
@@ -159,6 +172,28 @@ type ObjectAccidentEventInfo struct {
 	AlgorithmId  int32     // -1 если тип 1, или ид. АП
 	StartTime    time.Time // время начала инцидента
 	EndTime      time.Time // время завершения инцидента (или все 0, если не завершен)
+	IsEndMissing bool      // событие завершения не получено, EndTime - время полного состояния
+}
+
+// IsOpen возвращает true, если инцидент еще не завершен
+func (accident *ObjectAccidentEventInfo) IsOpen() bool {
+	return accident.EndTime.IsZero()
+}
+
+func (accident *ObjectAccidentEventInfo) IsNwaLeave() bool {
+	return accident.AccidentType == AccidentTypeNwaLeave
+}
+
+func (accident *ObjectAccidentEventInfo) IsProtectiveAlgorithm() bool {
+	return accident.AccidentType == AccidentTypeProtectiveAlgorithm
+}
+
+// Duration возвращает длительность инцидента, для незавершенного - время от начала до now
+func (accident *ObjectAccidentEventInfo) Duration(now time.Time) time.Duration {
+	if accident.IsOpen() {
+		return now.Sub(accident.StartTime)
+	}
+	return accident.EndTime.Sub(accident.StartTime)
 }
 
 /**
@@ -170,6 +205,19 @@ type ObjectNwaStateChangeEventInfo struct {
 	EventTime  time.Time
 }
 
+// Потеря или восстановление связи с устройством.
+// Запись события PackageEventTypeNoConnectionWithDevice (размер 18 байт с маркером, см. getEventRecordSize)
+// имеет ту же структуру, что и запись об отказе объекта, все поля little endian:
+// маркер (1), DeviceId int32 (4), ид. канала связи uint32 (4), признак потери связи (1), время в микросекундах uint64 (8).
+// Предварительно: описания протокола для этой записи нет, порядок полей восстановлен по размеру записи
+// и аналогии с записью об отказе и может быть уточнен.
+type DeviceNoConnectionEventInfo struct {
+	DeviceId     DeviceId
+	ConnectionId uint32 // ид. канала связи с устройством
+	IsStarted    bool   // true если связь потеряна
+	EventTime    time.Time
+}
+
 type ObjectFailureKey struct {
 	ObjectId  uint32
 	FailureId uint32
@@ -199,6 +247,28 @@ func getObjectFailureEvent(data []byte) *ObjectFailureEventInfo {
 	return result
 }
 
+const deviceNoConnectionEventSize = 17
+
+func getDeviceNoConnectionEvent(data []byte) (*DeviceNoConnectionEventInfo, error) {
+	if len(data) < deviceNoConnectionEventSize {
+		return nil, fmt.Errorf("incorrect size for no connection event")
+	}
+
+	var result = &DeviceNoConnectionEventInfo{}
+
+	result.DeviceId = DeviceId(binary.LittleEndian.Uint32(data))
+	if err := result.DeviceId.Validate(); err != nil {
+		return nil, err
+	}
+
+	result.ConnectionId = binary.LittleEndian.Uint32(data[4:])
+	result.IsStarted = data[8] != 0
+	// В пакете время в микросекундах
+	result.EventTime = GetTimeFromUnixMicroseconds(binary.LittleEndian.Uint64(data[9:]))
+
+	return result, nil
+}
+
 func getObjectFpEvent(data []byte) *ObjectFpEventInfo {
 	var result = &ObjectFpEventInfo{}
 
@@ -251,12 +321,17 @@ func getObjectAccidentEvent(data []byte) *ObjectAccidentEventInfo {
 	startTime := binary.LittleEndian.Uint64(data[9:])
 	endTime := binary.LittleEndian.Uint64(data[17:])
 
-	return &ObjectAccidentEventInfo{
+	result := &ObjectAccidentEventInfo{
 		AccidentType: accidentType,
 		AlgorithmId:  algorithmId,
 		ObjectId:     objectId,
-		StartTime:    GetTimeFromUnixMicroseconds(startTime),
-		EndTime:      GetTimeFromUnixMicroseconds(endTime)}
+		StartTime:    GetTimeFromUnixMicroseconds(startTime)}
+
+	// Нулевое время завершения означает, что инцидент не завершен
+	if endTime != 0 {
+		result.EndTime = GetTimeFromUnixMicroseconds(endTime)
+	}
+	return result
 }
 
 func (data *DataPackage) ParseFullObjectStatePackage() (map[uint32]uint16, error) {
@@ -316,6 +391,35 @@ func (data *DataPackage) ParseFullFailureStatePackage() (map[ObjectFailureKey]*O
 	return objectFailuresFullState, nil
 }
 
+// ParseFullAccidentStatePackage возвращает полное состояние инцидентов хоста.
+// Данные пакета - последовательность записей инцидентов (маркер PackageEventTypeAccidentInfo и 25 байт данных).
+func (data *DataPackage) ParseFullAccidentStatePackage() (map[ObjectAccidentKey]*ObjectAccidentEventInfo, error) {
+
+	if data.Format != PackageFormatFullAccidentStates {
+		return nil, fmt.Errorf("expected full accident package format")
+	}
+
+	if len(data.Data)%26 != 0 {
+		return nil, fmt.Errorf("accident full state data size should be 26 * nItems")
+	}
+
+	var accidentsFullState = make(map[ObjectAccidentKey]*ObjectAccidentEventInfo)
+
+	for curPos := 0; curPos < len(data.Data); curPos += 26 {
+		var marker = data.Data[curPos]
+
+		if marker != PackageEventTypeAccidentInfo {
+			return nil, fmt.Errorf("unexpected marker %d in accident full state message", marker)
+		}
+
+		accidentEvent := getObjectAccidentEvent(data.Data[curPos+1:])
+
+		accidentsFullState[ObjectAccidentKey{ObjectId: accidentEvent.ObjectId, AccidentId: accidentEvent.AlgorithmId}] = accidentEvent
+	}
+
+	return accidentsFullState, nil
+}
+
 type PackageEvents struct {
 	ObjectStates               map[uint32]uint16
 	ObjectFailuresChangeState  map[ObjectFailureKey]*ObjectFailureEventInfo
@@ -323,6 +427,8 @@ type PackageEvents struct {
 	ObjectFpChangeState        map[uint32]*ObjectFpEventInfo
 	ObjectNwaChangeState       map[uint32]*ObjectNwaStateLeaveEventInfo // Переход объекта в САНР или выход из АНР
 	ObjectNwaStateLeaveEnter   map[uint32]*ObjectNwaStateChangeEventInfo
+	DevicesNoConnection        map[DeviceId]*DeviceNoConnectionEventInfo
+	InvalidRecords             []error // ошибки некорректных записей, пропущенных при разборе пакета
 }
 
 func (events *PackageEvents) GetObjects() mapset.Set {
@@ -354,6 +460,35 @@ func (events *PackageEvents) GetObjects() mapset.Set {
 	return result
 }
 
+// GetEventKinds возвращает типы событий (PackageEventType*), содержащихся в пакете
+func (events *PackageEvents) GetEventKinds() mapset.Set {
+	result := mapset.NewSet()
+
+	if len(events.ObjectStates) > 0 {
+		result.Add(PackageEventTypeObjectState)
+	}
+	if len(events.ObjectFailuresChangeState) > 0 {
+		result.Add(PackageEventTypeFailureInfo)
+	}
+	if len(events.ObjectAccidentsChangeState) > 0 {
+		result.Add(PackageEventTypeAccidentInfo)
+	}
+	if len(events.ObjectFpChangeState) > 0 {
+		result.Add(PackageEventTypeFailurePrognosisAlgorithmInfo)
+	}
+	if len(events.ObjectNwaChangeState) > 0 {
+		result.Add(PackageEventTypeNwaLeaveInfo)
+	}
+	if len(events.ObjectNwaStateLeaveEnter) > 0 {
+		result.Add(PackageEventTypeNwaStateChangeInfo)
+	}
+	if len(events.DevicesNoConnection) > 0 {
+		result.Add(PackageEventTypeNoConnectionWithDevice)
+	}
+
+	return result
+}
+
 func getEventRecordSize(marker byte) (int, error) {
 	switch marker {
 	case PackageEventTypeFailureInfo:
@@ -393,7 +528,8 @@ func (data *DataPackage) ParseEventsPackage() (*PackageEvents, error) {
 		ObjectAccidentsChangeState: make(map[ObjectAccidentKey]*ObjectAccidentEventInfo),
 		ObjectFpChangeState:        make(map[uint32]*ObjectFpEventInfo),
 		ObjectNwaChangeState:       make(map[uint32]*ObjectNwaStateLeaveEventInfo),
-		ObjectNwaStateLeaveEnter:   make(map[uint32]*ObjectNwaStateChangeEventInfo)}
+		ObjectNwaStateLeaveEnter:   make(map[uint32]*ObjectNwaStateChangeEventInfo),
+		DevicesNoConnection:        make(map[DeviceId]*DeviceNoConnectionEventInfo)}
 
 	// Отфильтровываем пакеты об изменении состояния объекта
 	for i := 0; i < len(data.Data); {
@@ -418,6 +554,16 @@ func (data *DataPackage) ParseEventsPackage() (*PackageEvents, error) {
 					FailureId: failureEvent.FailureId}] = failureEvent
 			}
 
+		case PackageEventTypeNoConnectionWithDevice:
+			if !addChangeObjectStateEventsOnly {
+				// Некорректная запись не должна лишать остальных событий пакета
+				if noConnectionEvent, err := getDeviceNoConnectionEvent(data.Data[i+1 : i+size]); err != nil {
+					result.InvalidRecords = append(result.InvalidRecords, err)
+				} else {
+					result.DevicesNoConnection[noConnectionEvent.DeviceId] = noConnectionEvent
+				}
+			}
+
 		case PackageEventTypeAccidentInfo:
 			if !addChangeObjectStateEventsOnly {
 				accidentEvent := getObjectAccidentEvent(data.Data[i+1:])
@@ -466,3 +612,31 @@ func (data *DataPackage) ParseEventsPackage() (*PackageEvents, error) {
 
 	return result, nil
 }
+
+// ParseNotRespondingDevicesPackage возвращает список неотвечающих устройств хоста.
+// Пакет формата PackageFormatChangeNotRespondingDevices содержит полный текущий список: последовательность
+// идентификаторов устройств int32 little endian без заголовка, пустой список - все устройства отвечают.
+// Отдельного поля версии в пакете нет, структура данных определяется кодом формата пакета.
+// Предварительно: описания протокола для этого пакета нет, структура может быть уточнена.
+func (data *DataPackage) ParseNotRespondingDevicesPackage() ([]DeviceId, error) {
+
+	if data.Format != PackageFormatChangeNotRespondingDevices {
+		return nil, fmt.Errorf("expected not responding devices package format")
+	}
+
+	if len(data.Data)%4 != 0 {
+		return nil, fmt.Errorf("not responding devices data size should be 4 * nItems")
+	}
+
+	var result = make([]DeviceId, 0, len(data.Data)/4)
+
+	for i := 0; i < len(data.Data); i += 4 {
+		deviceId := DeviceId(binary.LittleEndian.Uint32(data.Data[i:]))
+		if err := deviceId.Validate(); err != nil {
+			return nil, err
+		}
+		result = append(result, deviceId)
+	}
+
+	return result, nil
+}
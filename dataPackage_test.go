@@ -259,7 +259,8 @@ func TestParseEventsWithAccidentsPackage(t *testing.T) {
 					},
 				},
 				ObjectFpChangeState:      make(map[uint32]*ObjectFpEventInfo),
-				ObjectNwaStateLeaveEnter: make(map[uint32]*ObjectNwaStateChangeEventInfo)}, isValid: true},
+				ObjectNwaStateLeaveEnter: make(map[uint32]*ObjectNwaStateChangeEventInfo),
+				DevicesNoConnection:      make(map[DeviceId]*DeviceNoConnectionEventInfo)}, isValid: true},
 	}
 
 	for _, test := range tests {
@@ -365,7 +366,8 @@ func TestParseEventsPackage(t *testing.T) {
 				},
 				ObjectAccidentsChangeState: make(map[ObjectAccidentKey]*ObjectAccidentEventInfo),
 				ObjectFpChangeState:        make(map[uint32]*ObjectFpEventInfo),
-				ObjectNwaStateLeaveEnter:   make(map[uint32]*ObjectNwaStateChangeEventInfo)}, true},
+				ObjectNwaStateLeaveEnter:   make(map[uint32]*ObjectNwaStateChangeEventInfo),
+				DevicesNoConnection:        make(map[DeviceId]*DeviceNoConnectionEventInfo)}, true},
 
 		{"test3", &DataPackage{Format: PackageFormatEvents, BitsPerSensor: 8, SensorCount: 21, DataSize: 21,
 			Data: test3Data,
@@ -378,7 +380,8 @@ func TestParseEventsPackage(t *testing.T) {
 					100: {ObjectId: 100, AlgorithmId: 4, StepIndex: 67, EventTime: test3_100_StartTime},
 				},
 
-				ObjectNwaStateLeaveEnter: make(map[uint32]*ObjectNwaStateChangeEventInfo)}, true},
+				ObjectNwaStateLeaveEnter: make(map[uint32]*ObjectNwaStateChangeEventInfo),
+				DevicesNoConnection:      make(map[DeviceId]*DeviceNoConnectionEventInfo)}, true},
 
 		{"test4", &DataPackage{Format: PackageFormatEvents, BitsPerSensor: 8, SensorCount: 14, DataSize: 14, Data: []byte{
 			PackageEventTypeObjectState, 100, 0, 0, 0, 1, 0,
@@ -389,7 +392,8 @@ func TestParseEventsPackage(t *testing.T) {
 				ObjectNwaChangeState:       make(map[uint32]*ObjectNwaStateLeaveEventInfo),
 				ObjectAccidentsChangeState: make(map[ObjectAccidentKey]*ObjectAccidentEventInfo),
 				ObjectFpChangeState:        make(map[uint32]*ObjectFpEventInfo),
-				ObjectNwaStateLeaveEnter:   make(map[uint32]*ObjectNwaStateChangeEventInfo)}, true},
+				ObjectNwaStateLeaveEnter:   make(map[uint32]*ObjectNwaStateChangeEventInfo),
+				DevicesNoConnection:        make(map[DeviceId]*DeviceNoConnectionEventInfo)}, true},
 
 		{"test5", &DataPackage{Format: PackageFormatEvents, BitsPerSensor: 8, SensorCount: 32, DataSize: 32,
 			Data: append(append([]byte{
@@ -402,7 +406,8 @@ func TestParseEventsPackage(t *testing.T) {
 				ObjectNwaChangeState:       make(map[uint32]*ObjectNwaStateLeaveEventInfo),
 				ObjectAccidentsChangeState: make(map[ObjectAccidentKey]*ObjectAccidentEventInfo),
 				ObjectFpChangeState:        make(map[uint32]*ObjectFpEventInfo),
-				ObjectNwaStateLeaveEnter:   make(map[uint32]*ObjectNwaStateChangeEventInfo)},
+				ObjectNwaStateLeaveEnter:   make(map[uint32]*ObjectNwaStateChangeEventInfo),
+				DevicesNoConnection:        make(map[DeviceId]*DeviceNoConnectionEventInfo)},
 			true},
 
 		{"test6", &DataPackage{Format: PackageFormatEvents, BitsPerSensor: 8, SensorCount: 29, DataSize: 29,
@@ -417,6 +422,7 @@ func TestParseEventsPackage(t *testing.T) {
 					100: {ObjectId: 100, NwaStateId: 23, EventTime: test6StartTime},
 					200: {ObjectId: 200, NwaStateId: -1, EventTime: test6StartTime},
 				},
+				DevicesNoConnection: make(map[DeviceId]*DeviceNoConnectionEventInfo),
 			},
 			true},
 		{"test7", &DataPackage{Format: PackageFormatChangeObjectStates, BitsPerSensor: 8, SensorCount: 14, DataSize: 14, Data: []byte{
@@ -428,7 +434,8 @@ func TestParseEventsPackage(t *testing.T) {
 				ObjectNwaChangeState:       make(map[uint32]*ObjectNwaStateLeaveEventInfo),
 				ObjectAccidentsChangeState: make(map[ObjectAccidentKey]*ObjectAccidentEventInfo),
 				ObjectFpChangeState:        make(map[uint32]*ObjectFpEventInfo),
-				ObjectNwaStateLeaveEnter:   make(map[uint32]*ObjectNwaStateChangeEventInfo)}, true},
+				ObjectNwaStateLeaveEnter:   make(map[uint32]*ObjectNwaStateChangeEventInfo),
+				DevicesNoConnection:        make(map[DeviceId]*DeviceNoConnectionEventInfo)}, true},
 
 		{"test8", &DataPackage{Format: PackageFormatChangeFailureStates, BitsPerSensor: 8, SensorCount: 18, DataSize: 18,
 			Data: append([]byte{
@@ -441,7 +448,8 @@ func TestParseEventsPackage(t *testing.T) {
 				ObjectNwaChangeState:       make(map[uint32]*ObjectNwaStateLeaveEventInfo),
 				ObjectAccidentsChangeState: make(map[ObjectAccidentKey]*ObjectAccidentEventInfo),
 				ObjectFpChangeState:        make(map[uint32]*ObjectFpEventInfo),
-				ObjectNwaStateLeaveEnter:   make(map[uint32]*ObjectNwaStateChangeEventInfo)}, true},
+				ObjectNwaStateLeaveEnter:   make(map[uint32]*ObjectNwaStateChangeEventInfo),
+				DevicesNoConnection:        make(map[DeviceId]*DeviceNoConnectionEventInfo)}, true},
 	}
 
 	for _, test := range tests {
@@ -94,10 +94,12 @@ func GetDataConverterFunction(bitsPerSensor byte) (GetDataFunction, error) {
 	return handler, nil
 }
 
+// GetSpecialDeviceForHost не проверяет переполнение, следует использовать NewSpecialDeviceId
 func GetSpecialDeviceForHost(hostId int) int32 {
 	return int32(hostId) + MaxDeviceId
 }
 
+// GetHostForSpecialDevice аналогичен DeviceId.Host
 func GetHostForSpecialDevice(deviceId int32) (int, error) {
 	if deviceId < MaxDeviceId {
 		return 0, fmt.Errorf("not special device id")
@@ -0,0 +1,291 @@
+package core
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DeviceStatusUnknown byte = 0 // нет сведений или нет данных дольше SilenceTimeout
+	DeviceStatusOnline  byte = 1
+	DeviceStatusOffline byte = 2 // устройство в списке неотвечающих или нет связи с устройством
+)
+
+const (
+	DefaultDeviceSilenceTimeout = time.Minute
+	DefaultDeviceMaxHistory     = 1000
+)
+
+type DeviceStatusTransition struct {
+	Time   time.Time
+	Status byte
+}
+
+type DeviceAvailabilityOptions struct {
+	SilenceTimeout time.Duration // DefaultDeviceSilenceTimeout если 0
+	MaxHistory     int           // количество хранимых переходов на устройство, DefaultDeviceMaxHistory если 0
+	Clock          Clock         // часы для определения времени получения пакетов в Apply
+}
+
+type DeviceStatusHandler func(hostId int32, deviceId DeviceId, previous byte, current byte, transitionTime time.Time)
+
+type deviceAvailability struct {
+	status        byte
+	lastSeen      time.Time
+	notResponding bool
+	noConnection  bool
+	history       []DeviceStatusTransition
+}
+
+// DeviceAvailabilityTracker определяет доступность устройств по пакетам heartbeat и измерений,
+// спискам неотвечающих устройств и событиям потери связи с устройством.
+// Состояние устройства ведется отдельно для каждого хоста, сообщающего о нем.
+// Время переходов - локальное время получения пакетов.
+type DeviceAvailabilityTracker struct {
+	mutex    sync.RWMutex
+	options  DeviceAvailabilityOptions
+	hosts    map[int32]map[DeviceId]*deviceAvailability
+	onChange DeviceStatusHandler
+}
+
+type deviceStatusChange struct {
+	hostId   int32
+	deviceId DeviceId
+	previous byte
+	current  byte
+	time     time.Time
+}
+
+func NewDeviceAvailabilityTracker(options DeviceAvailabilityOptions, onChange DeviceStatusHandler) *DeviceAvailabilityTracker {
+	if options.SilenceTimeout <= 0 {
+		options.SilenceTimeout = DefaultDeviceSilenceTimeout
+	}
+	if options.MaxHistory <= 0 {
+		options.MaxHistory = DefaultDeviceMaxHistory
+	}
+
+	return &DeviceAvailabilityTracker{
+		options:  options,
+		hosts:    make(map[int32]map[DeviceId]*deviceAvailability),
+		onChange: onChange}
+}
+
+func (tracker *DeviceAvailabilityTracker) Apply(networkPackage *NetworkPackage) error {
+	return tracker.ApplyAt(networkPackage, getClock(tracker.options.Clock).Now())
+}
+
+// ApplyAt обрабатывает сетевой пакет, полученный в момент receiveTime
+func (tracker *DeviceAvailabilityTracker) ApplyAt(networkPackage *NetworkPackage, receiveTime time.Time) error {
+	data := &networkPackage.Data
+
+	var notResponding []DeviceId
+	var events *PackageEvents
+	var err error
+
+	switch data.Format {
+	case PackageFormatChangeNotRespondingDevices:
+		if notResponding, err = data.ParseNotRespondingDevicesPackage(); err != nil {
+			return err
+		}
+	case PackageFormatEvents:
+		if events, err = data.ParseEventsPackage(); err != nil {
+			return err
+		}
+	}
+
+	var changes []deviceStatusChange
+
+	tracker.mutex.Lock()
+
+	switch data.Format {
+	case PackageFormatHeartbeat, PackageFormatData:
+		if !data.DeviceId.IsSpecial() {
+			device := tracker.getDevice(networkPackage.HostId, data.DeviceId)
+			device.lastSeen = receiveTime
+		}
+
+	case PackageFormatChangeNotRespondingDevices:
+		list := make(map[DeviceId]bool, len(notResponding))
+		for _, deviceId := range notResponding {
+			list[deviceId] = true
+			tracker.getDevice(networkPackage.HostId, deviceId)
+		}
+		for deviceId, device := range tracker.hosts[networkPackage.HostId] {
+			device.notResponding = list[deviceId]
+		}
+
+	case PackageFormatEvents:
+		for deviceId, event := range events.DevicesNoConnection {
+			device := tracker.getDevice(networkPackage.HostId, deviceId)
+			device.noConnection = event.IsStarted
+			if !event.IsStarted {
+				device.lastSeen = receiveTime
+			}
+		}
+	}
+
+	changes = tracker.updateStatuses(receiveTime)
+	tracker.mutex.Unlock()
+
+	tracker.notify(changes)
+	return nil
+}
+
+// CheckSilence переводит в неизвестное состояние устройства, от которых нет данных дольше SilenceTimeout.
+// Следует вызывать периодически.
+func (tracker *DeviceAvailabilityTracker) CheckSilence(now time.Time) {
+	tracker.mutex.Lock()
+	changes := tracker.updateStatuses(now)
+	tracker.mutex.Unlock()
+
+	tracker.notify(changes)
+}
+
+func (tracker *DeviceAvailabilityTracker) GetStatus(hostId int32, deviceId DeviceId) byte {
+	tracker.mutex.RLock()
+	defer tracker.mutex.RUnlock()
+
+	device, ok := tracker.hosts[hostId][deviceId]
+	if !ok {
+		return DeviceStatusUnknown
+	}
+	return device.status
+}
+
+// GetDevices возвращает устройства, о которых сообщал хост
+func (tracker *DeviceAvailabilityTracker) GetDevices(hostId int32) []DeviceId {
+	tracker.mutex.RLock()
+	defer tracker.mutex.RUnlock()
+
+	devices := tracker.hosts[hostId]
+	result := make([]DeviceId, 0, len(devices))
+	for deviceId := range devices {
+		result = append(result, deviceId)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+func (tracker *DeviceAvailabilityTracker) GetHistory(hostId int32, deviceId DeviceId) []DeviceStatusTransition {
+	tracker.mutex.RLock()
+	defer tracker.mutex.RUnlock()
+
+	device, ok := tracker.hosts[hostId][deviceId]
+	if !ok {
+		return nil
+	}
+	return append([]DeviceStatusTransition(nil), device.history...)
+}
+
+// GetAvailability возвращает долю времени (в процентах) интервала [from, to), в течение которой устройство
+// было доступно. Время до первого известного перехода считается временем недоступности.
+func (tracker *DeviceAvailabilityTracker) GetAvailability(hostId int32, deviceId DeviceId, from time.Time, to time.Time) float64 {
+	if !to.After(from) {
+		return 0
+	}
+
+	tracker.mutex.RLock()
+	defer tracker.mutex.RUnlock()
+
+	device, ok := tracker.hosts[hostId][deviceId]
+	if !ok {
+		return 0
+	}
+
+	var online time.Duration
+	for i, transition := range device.history {
+		if transition.Status != DeviceStatusOnline {
+			continue
+		}
+
+		start := transition.Time
+		end := to
+		if i+1 < len(device.history) {
+			end = device.history[i+1].Time
+		}
+
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			online += end.Sub(start)
+		}
+	}
+
+	return float64(online) * 100 / float64(to.Sub(from))
+}
+
+func (tracker *DeviceAvailabilityTracker) getDevice(hostId int32, deviceId DeviceId) *deviceAvailability {
+	devices, ok := tracker.hosts[hostId]
+	if !ok {
+		devices = make(map[DeviceId]*deviceAvailability)
+		tracker.hosts[hostId] = devices
+	}
+
+	device, ok := devices[deviceId]
+	if !ok {
+		device = &deviceAvailability{}
+		devices[deviceId] = device
+	}
+	return device
+}
+
+func (tracker *DeviceAvailabilityTracker) updateStatuses(now time.Time) []deviceStatusChange {
+	var changes []deviceStatusChange
+
+	for hostId, devices := range tracker.hosts {
+		for deviceId, device := range devices {
+			if change, ok := tracker.updateStatus(device, now); ok {
+				change.hostId = hostId
+				change.deviceId = deviceId
+				changes = append(changes, change)
+			}
+		}
+	}
+
+	return changes
+}
+
+func (tracker *DeviceAvailabilityTracker) updateStatus(device *deviceAvailability, now time.Time) (deviceStatusChange, bool) {
+	status := DeviceStatusUnknown
+	transitionTime := now
+
+	switch {
+	case device.notResponding || device.noConnection:
+		status = DeviceStatusOffline
+	case !device.lastSeen.IsZero() && now.Sub(device.lastSeen) <= tracker.options.SilenceTimeout:
+		status = DeviceStatusOnline
+	case !device.lastSeen.IsZero():
+		transitionTime = device.lastSeen.Add(tracker.options.SilenceTimeout)
+	}
+
+	if status == device.status && len(device.history) > 0 {
+		return deviceStatusChange{}, false
+	}
+	if status == device.status && status == DeviceStatusUnknown {
+		return deviceStatusChange{}, false
+	}
+
+	change := deviceStatusChange{previous: device.status, current: status, time: transitionTime}
+
+	device.status = status
+	device.history = append(device.history, DeviceStatusTransition{Time: transitionTime, Status: status})
+	if len(device.history) > tracker.options.MaxHistory {
+		device.history = device.history[len(device.history)-tracker.options.MaxHistory:]
+	}
+
+	return change, true
+}
+
+func (tracker *DeviceAvailabilityTracker) notify(changes []deviceStatusChange) {
+	if tracker.onChange == nil {
+		return
+	}
+	for _, change := range changes {
+		tracker.onChange(change.hostId, change.deviceId, change.previous, change.current, change.time)
+	}
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseNotRespondingDevicesPackage(t *testing.T) {
+	devices, err := (&DataPackage{Format: PackageFormatChangeNotRespondingDevices,
+		Data: []byte{10, 0, 0, 0, 20, 0, 0, 0}}).ParseNotRespondingDevicesPackage()
+	assert.Nil(t, err)
+	assert.Equal(t, []DeviceId{10, 20}, devices)
+
+	_, err = (&DataPackage{Format: PackageFormatChangeNotRespondingDevices, Data: []byte{10, 0}}).ParseNotRespondingDevicesPackage()
+	assert.NotNil(t, err)
+
+	_, err = (&DataPackage{Format: PackageFormatChangeNotRespondingDevices,
+		Data: []byte{0xFF, 0xFF, 0xFF, 0xFF}}).ParseNotRespondingDevicesPackage()
+	assert.NotNil(t, err)
+
+	_, err = (&DataPackage{Format: PackageFormatData}).ParseNotRespondingDevicesPackage()
+	assert.NotNil(t, err)
+
+	_, timeSlice := getTimeAndSlice(time.Now())
+	// Некорректная запись пропускается, остальные события пакета разбираются
+	data := append([]byte{PackageEventTypeNoConnectionWithDevice, 0xFF, 0xFF, 0xFF, 0xFF, 1, 0, 0, 0, 1}, timeSlice...)
+	data = append(data, PackageEventTypeObjectState, 200, 0, 0, 0, 3, 0)
+	events, err := (&DataPackage{Format: PackageFormatEvents, Data: data}).ParseEventsPackage()
+	assert.Nil(t, err)
+	assert.Len(t, events.InvalidRecords, 1)
+	assert.Empty(t, events.DevicesNoConnection)
+	assert.Equal(t, map[uint32]uint16{200: 3}, events.ObjectStates)
+}
+
+func TestDeviceAvailabilityTracker(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	_, timeSlice := getTimeAndSlice(start)
+
+	var transitions []byte
+	tracker := NewDeviceAvailabilityTracker(DeviceAvailabilityOptions{SilenceTimeout: time.Minute},
+		func(hostId int32, deviceId DeviceId, previous byte, current byte, transitionTime time.Time) {
+			transitions = append(transitions, current)
+		})
+
+	heartbeat := &NetworkPackage{HostId: 1, Data: DataPackage{DeviceId: 10, Format: PackageFormatHeartbeat}}
+
+	assert.Nil(t, tracker.ApplyAt(heartbeat, start))
+	assert.Equal(t, DeviceStatusOnline, tracker.GetStatus(1, 10))
+
+	// Устройство в списке неотвечающих
+	assert.Nil(t, tracker.ApplyAt(&NetworkPackage{HostId: 1, Data: DataPackage{
+		Format: PackageFormatChangeNotRespondingDevices, Data: []byte{10, 0, 0, 0}}}, start.Add(time.Minute)))
+	assert.Equal(t, DeviceStatusOffline, tracker.GetStatus(1, 10))
+
+	assert.Nil(t, tracker.ApplyAt(heartbeat, start.Add(time.Minute*2)))
+	assert.Equal(t, DeviceStatusOffline, tracker.GetStatus(1, 10))
+	assert.Nil(t, tracker.ApplyAt(&NetworkPackage{HostId: 1, Data: DataPackage{
+		Format: PackageFormatChangeNotRespondingDevices}}, start.Add(time.Minute*2)))
+	assert.Equal(t, DeviceStatusOnline, tracker.GetStatus(1, 10))
+
+	// Потеря связи с устройством
+	noConnection := append([]byte{PackageEventTypeNoConnectionWithDevice, 10, 0, 0, 0, 1, 0, 0, 0, 1}, timeSlice...)
+	assert.Nil(t, tracker.ApplyAt(&NetworkPackage{HostId: 1, Data: DataPackage{
+		Format: PackageFormatEvents, Data: noConnection}}, start.Add(time.Minute*3)))
+	assert.Equal(t, DeviceStatusOffline, tracker.GetStatus(1, 10))
+
+	noConnection[9] = 0
+	assert.Nil(t, tracker.ApplyAt(&NetworkPackage{HostId: 1, Data: DataPackage{
+		Format: PackageFormatEvents, Data: noConnection}}, start.Add(time.Minute*4)))
+	assert.Equal(t, DeviceStatusOnline, tracker.GetStatus(1, 10))
+
+	// Нет данных дольше SilenceTimeout
+	tracker.CheckSilence(start.Add(time.Minute * 6))
+	assert.Equal(t, DeviceStatusUnknown, tracker.GetStatus(1, 10))
+
+	assert.Equal(t, []byte{DeviceStatusOnline, DeviceStatusOffline, DeviceStatusOnline,
+		DeviceStatusOffline, DeviceStatusOnline, DeviceStatusUnknown}, transitions)
+	assert.Len(t, tracker.GetHistory(1, 10), 6)
+	assert.Equal(t, start.Add(time.Minute*5), tracker.GetHistory(1, 10)[5].Time)
+
+	// Доступно: 0-1 и 2-3 минуты, 4-5 минуты
+	assert.InDelta(t, 50.0, tracker.GetAvailability(1, 10, start, start.Add(time.Minute*6)), 0.001)
+	assert.Equal(t, DeviceStatusUnknown, tracker.GetStatus(1, 20))
+}
+
+func TestDeviceAvailabilityTrackerHosts(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	var hosts []int32
+	tracker := NewDeviceAvailabilityTracker(DeviceAvailabilityOptions{SilenceTimeout: time.Minute},
+		func(hostId int32, deviceId DeviceId, previous byte, current byte, transitionTime time.Time) {
+			hosts = append(hosts, hostId)
+		})
+
+	// Устройство 10 видно двум хостам, состояние ведется для каждого хоста отдельно
+	assert.Nil(t, tracker.ApplyAt(&NetworkPackage{HostId: 1, Data: DataPackage{DeviceId: 10, Format: PackageFormatHeartbeat}}, start))
+	assert.Nil(t, tracker.ApplyAt(&NetworkPackage{HostId: 2, Data: DataPackage{
+		Format: PackageFormatChangeNotRespondingDevices, Data: []byte{10, 0, 0, 0}}}, start))
+
+	assert.Equal(t, DeviceStatusOnline, tracker.GetStatus(1, 10))
+	assert.Equal(t, DeviceStatusOffline, tracker.GetStatus(2, 10))
+	assert.Equal(t, []DeviceId{10}, tracker.GetDevices(1))
+	assert.Equal(t, []DeviceId{10}, tracker.GetDevices(2))
+	assert.Empty(t, tracker.GetDevices(3))
+	assert.Equal(t, []int32{1, 2}, hosts)
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"fmt"
+	"math"
+)
+
+// DeviceId - идентификатор устройства.
+// Идентификаторы начиная с MaxDeviceId зарезервированы для специальных устройств хостов,
+// от имени которых передается диагностика хоста.
+type DeviceId int32
+
+// NewSpecialDeviceId возвращает идентификатор специального устройства хоста
+func NewSpecialDeviceId(hostId int) (DeviceId, error) {
+	if hostId < 0 {
+		return 0, fmt.Errorf("invalid host id %d", hostId)
+	}
+	if hostId > math.MaxInt32-MaxDeviceId {
+		return 0, fmt.Errorf("host id %d is too large for special device id", hostId)
+	}
+	return DeviceId(hostId + MaxDeviceId), nil
+}
+
+// IsSpecial возвращает true для специального устройства хоста
+func (deviceId DeviceId) IsSpecial() bool {
+	return deviceId >= MaxDeviceId
+}
+
+// Host возвращает идентификатор хоста для специального устройства
+func (deviceId DeviceId) Host() (int, error) {
+	if !deviceId.IsSpecial() {
+		return 0, fmt.Errorf("not special device id %d", int32(deviceId))
+	}
+	return int(deviceId - MaxDeviceId), nil
+}
+
+func (deviceId DeviceId) Validate() error {
+	if deviceId < 0 {
+		return fmt.Errorf("invalid device id %d", int32(deviceId))
+	}
+	return nil
+}
+
+func (deviceId DeviceId) String() string {
+	if deviceId.IsSpecial() {
+		return fmt.Sprintf("host:%d", int32(deviceId-MaxDeviceId))
+	}
+	return fmt.Sprintf("%d", int32(deviceId))
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestSpecialDeviceId(t *testing.T) {
+	deviceId, err := NewSpecialDeviceId(5)
+	assert.Nil(t, err)
+	assert.True(t, deviceId.IsSpecial())
+	assert.Equal(t, DeviceId(GetSpecialDeviceForHost(5)), deviceId)
+	assert.Equal(t, "host:5", deviceId.String())
+
+	hostId, err := deviceId.Host()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, hostId)
+
+	_, err = NewSpecialDeviceId(-1)
+	assert.NotNil(t, err)
+	_, err = NewSpecialDeviceId(math.MaxInt32 - MaxDeviceId + 1)
+	assert.NotNil(t, err)
+
+	deviceId, err = NewSpecialDeviceId(math.MaxInt32 - MaxDeviceId)
+	assert.Nil(t, err)
+	assert.Equal(t, DeviceId(math.MaxInt32), deviceId)
+}
+
+func TestDeviceId(t *testing.T) {
+	deviceId := DeviceId(10)
+	assert.False(t, deviceId.IsSpecial())
+	assert.Equal(t, "10", deviceId.String())
+	assert.Nil(t, deviceId.Validate())
+
+	_, err := deviceId.Host()
+	assert.NotNil(t, err)
+
+	assert.NotNil(t, DeviceId(-1).Validate())
+}
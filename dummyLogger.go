@@ -7,25 +7,49 @@ func (*DummyLogger) Clear() {
 
 }
 
-func (*DummyLogger) Info(message string) {
+func (*DummyLogger) Info(message string, fields ...LogField) {
 
 }
 
-func (*DummyLogger) Error(message string) {
+func (*DummyLogger) Error(message string, fields ...LogField) {
 
 }
 
-func (*DummyLogger) Warning(message string) {
+func (*DummyLogger) Warning(message string, fields ...LogField) {
 
 }
-func (*DummyLogger) Trace(message string) {
+func (*DummyLogger) Debug(message string, fields ...LogField) {
 
 }
 
-func (*DummyLogger) FatalError(message string) {
+func (*DummyLogger) Trace(message string, fields ...LogField) {
+
+}
+
+func (*DummyLogger) FatalError(message string, fields ...LogField) {
 
 }
 
 func (*DummyLogger) IsTraceEnabled() bool {
 	return false
 }
+
+func (*DummyLogger) IsEnabled(level LogLevel) bool {
+	return false
+}
+
+func (*DummyLogger) SetLevel(level LogLevel) {
+
+}
+
+func (logger *DummyLogger) Component(name string) Logger {
+	return logger
+}
+
+func (*DummyLogger) SetComponentLevel(name string, level LogLevel) {
+
+}
+
+func (logger *DummyLogger) With(fields ...LogField) Logger {
+	return logger
+}
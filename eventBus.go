@@ -0,0 +1,332 @@
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BusMessage - сообщение шины: сетевой пакет и, для пакетов событий, результат его разбора.
+// Одно и то же сообщение передается всем подписчикам, поэтому подписчики не должны изменять
+// Package и Events, в том числе события по указателям из Events. Для изменения следует сделать копию.
+type BusMessage struct {
+	Package *NetworkPackage
+	Events  *PackageEvents
+}
+
+// BusFilter задает условия отбора сообщений для подписчика.
+// Пустой список означает отсутствие ограничения по соответствующему признаку.
+// Пакеты измерений специальных устройств хостов не относятся к потоку измерений полевых устройств
+// и передаются только подписчикам, явно указавшим устройство хоста в DeviceIds.
+type BusFilter struct {
+	HostIds    []int32
+	DeviceIds  []DeviceId
+	ObjectIds  []uint32 // объекты из событий пакета
+	Formats    []byte   // PackageFormat*
+	EventKinds []byte   // PackageEventType*
+}
+
+const (
+	BusPolicyDropNewest byte = 0 // при переполнении очереди новое сообщение отбрасывается
+	BusPolicyDropOldest byte = 1 // при переполнении очереди отбрасывается самое старое сообщение
+	BusPolicyBlock      byte = 2 // при переполнении очереди публикация ожидает освобождения места
+)
+
+const DefaultBusQueueSize = 1024
+
+type SubscriberOptions struct {
+	QueueSize int  // размер очереди, DefaultBusQueueSize если 0
+	Policy    byte // BusPolicy*
+	// Для BusPolicyBlock - максимальное время ожидания, после которого сообщение отбрасывается.
+	// 0 - ожидать без ограничения
+	BlockTimeout time.Duration
+	Clock        Clock // часы для отсчета BlockTimeout
+}
+
+// SlowSubscriberHandler вызывается, когда очередь подписчика переполнена
+type SlowSubscriberHandler func(subscription *Subscription)
+
+type busFilter struct {
+	hostIds    map[int32]bool
+	deviceIds  map[DeviceId]bool
+	objectIds  map[uint32]bool
+	formats    map[byte]bool
+	eventKinds map[byte]bool
+}
+
+type Subscription struct {
+	bus      *EventBus
+	filter   busFilter
+	options  SubscriberOptions
+	messages chan *BusMessage
+	done     chan struct{}
+	once     sync.Once
+	dropped  uint64
+
+	// Публикация выполняется без блокировки шины: sendMutex исключает отправку в закрытый канал
+	sendMutex sync.RWMutex
+	isClosed  bool
+}
+
+// Messages возвращает канал сообщений подписчика. Канал закрывается при отписке или закрытии шины.
+func (subscription *Subscription) Messages() <-chan *BusMessage {
+	return subscription.messages
+}
+
+// Dropped возвращает количество сообщений, отброшенных из-за переполнения очереди
+func (subscription *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&subscription.dropped)
+}
+
+func (subscription *Subscription) Unsubscribe() {
+	subscription.bus.unsubscribe(subscription)
+}
+
+// EventBus рассылает сетевые пакеты и события подписчикам с учетом их фильтров
+type EventBus struct {
+	mutex         sync.RWMutex
+	subscriptions map[*Subscription]bool
+	onSlow        SlowSubscriberHandler
+}
+
+func NewEventBus(onSlow SlowSubscriberHandler) *EventBus {
+	return &EventBus{subscriptions: make(map[*Subscription]bool), onSlow: onSlow}
+}
+
+func (bus *EventBus) Subscribe(filter BusFilter, options SubscriberOptions) *Subscription {
+	if options.QueueSize <= 0 {
+		options.QueueSize = DefaultBusQueueSize
+	}
+
+	subscription := &Subscription{
+		bus:      bus,
+		filter:   newBusFilter(filter),
+		options:  options,
+		messages: make(chan *BusMessage, options.QueueSize),
+		done:     make(chan struct{})}
+
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	bus.subscriptions[subscription] = true
+	return subscription
+}
+
+// Publish разбирает события пакета (для форматов событий) и рассылает его подписчикам.
+// При ошибке разбора пакет рассылается без событий и возвращается ошибка.
+func (bus *EventBus) Publish(networkPackage *NetworkPackage) error {
+	message := &BusMessage{Package: networkPackage}
+
+	var err error
+
+	switch networkPackage.Data.Format {
+	case PackageFormatEvents, PackageFormatChangeObjectStates, PackageFormatChangeFailureStates:
+		message.Events, err = networkPackage.Data.ParseEventsPackage()
+	}
+
+	bus.PublishMessage(message)
+	return err
+}
+
+// PublishMessage рассылает сообщение подписчикам. Ожидание подписчика с BusPolicyBlock
+// не блокирует подписку, отписку и публикацию из других горутин.
+func (bus *EventBus) PublishMessage(message *BusMessage) {
+	var matched []*Subscription
+
+	bus.mutex.RLock()
+	for subscription := range bus.subscriptions {
+		if subscription.filter.match(message) {
+			matched = append(matched, subscription)
+		}
+	}
+	bus.mutex.RUnlock()
+
+	var slow []*Subscription
+	for _, subscription := range matched {
+		if !subscription.send(message) {
+			slow = append(slow, subscription)
+		}
+	}
+
+	if bus.onSlow == nil {
+		return
+	}
+	for _, subscription := range slow {
+		bus.onSlow(subscription)
+	}
+}
+
+// Close отписывает всех подписчиков
+func (bus *EventBus) Close() {
+	bus.mutex.RLock()
+	var subscriptions []*Subscription
+	for subscription := range bus.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	bus.mutex.RUnlock()
+
+	for _, subscription := range subscriptions {
+		bus.unsubscribe(subscription)
+	}
+}
+
+func (bus *EventBus) unsubscribe(subscription *Subscription) {
+	subscription.once.Do(func() {
+		// Прерываем ожидающую публикацию до захвата блокировки
+		close(subscription.done)
+
+		bus.mutex.Lock()
+		delete(bus.subscriptions, subscription)
+		bus.mutex.Unlock()
+
+		subscription.sendMutex.Lock()
+		defer subscription.sendMutex.Unlock()
+
+		subscription.isClosed = true
+		close(subscription.messages)
+	})
+}
+
+// send помещает сообщение в очередь подписчика, возвращает false если очередь была переполнена
+func (subscription *Subscription) send(message *BusMessage) bool {
+	subscription.sendMutex.RLock()
+	defer subscription.sendMutex.RUnlock()
+
+	if subscription.isClosed {
+		return true
+	}
+
+	select {
+	case subscription.messages <- message:
+		return true
+	case <-subscription.done:
+		return true
+	default:
+	}
+
+	switch subscription.options.Policy {
+	case BusPolicyDropOldest:
+		for {
+			select {
+			case <-subscription.messages:
+				atomic.AddUint64(&subscription.dropped, 1)
+			default:
+			}
+
+			select {
+			case subscription.messages <- message:
+				return false
+			default:
+			}
+		}
+
+	case BusPolicyBlock:
+		var timeout <-chan time.Time
+		if subscription.options.BlockTimeout > 0 {
+			timer := getClock(subscription.options.Clock).NewTimer(subscription.options.BlockTimeout)
+			defer timer.Stop()
+			timeout = timer.C()
+		}
+
+		select {
+		case subscription.messages <- message:
+		case <-subscription.done:
+		case <-timeout:
+			atomic.AddUint64(&subscription.dropped, 1)
+		}
+		return false
+
+	default:
+		atomic.AddUint64(&subscription.dropped, 1)
+		return false
+	}
+}
+
+func newBusFilter(filter BusFilter) busFilter {
+	result := busFilter{}
+
+	if len(filter.HostIds) > 0 {
+		result.hostIds = make(map[int32]bool)
+		for _, id := range filter.HostIds {
+			result.hostIds[id] = true
+		}
+	}
+	if len(filter.DeviceIds) > 0 {
+		result.deviceIds = make(map[DeviceId]bool)
+		for _, id := range filter.DeviceIds {
+			result.deviceIds[id] = true
+		}
+	}
+	if len(filter.ObjectIds) > 0 {
+		result.objectIds = make(map[uint32]bool)
+		for _, id := range filter.ObjectIds {
+			result.objectIds[id] = true
+		}
+	}
+	if len(filter.Formats) > 0 {
+		result.formats = make(map[byte]bool)
+		for _, format := range filter.Formats {
+			result.formats[format] = true
+		}
+	}
+	if len(filter.EventKinds) > 0 {
+		result.eventKinds = make(map[byte]bool)
+		for _, kind := range filter.EventKinds {
+			result.eventKinds[kind] = true
+		}
+	}
+
+	return result
+}
+
+func (filter *busFilter) match(message *BusMessage) bool {
+	if message.Package != nil {
+		if filter.hostIds != nil && !filter.hostIds[message.Package.HostId] {
+			return false
+		}
+		if filter.deviceIds != nil && !filter.deviceIds[message.Package.Data.DeviceId] {
+			return false
+		}
+		if filter.deviceIds == nil && message.Package.Data.Format == PackageFormatData &&
+			message.Package.IsHostPackage() {
+			return false
+		}
+		if filter.formats != nil && !filter.formats[message.Package.Data.Format] {
+			return false
+		}
+	} else if filter.hostIds != nil || filter.deviceIds != nil || filter.formats != nil {
+		return false
+	}
+
+	if filter.objectIds == nil && filter.eventKinds == nil {
+		return true
+	}
+
+	if message.Events == nil {
+		return false
+	}
+
+	if filter.eventKinds != nil {
+		found := false
+		for _, kind := range message.Events.GetEventKinds().ToSlice() {
+			if filter.eventKinds[kind.(byte)] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if filter.objectIds != nil {
+		for _, objectId := range message.Events.GetObjects().ToSlice() {
+			if filter.objectIds[uint32(objectId.(int))] {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
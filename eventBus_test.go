@@ -0,0 +1,110 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestEventBusFilter(t *testing.T) {
+	bus := NewEventBus(nil)
+	defer bus.Close()
+
+	hostSubscription := bus.Subscribe(BusFilter{HostIds: []int32{1}}, SubscriberOptions{})
+	objectSubscription := bus.Subscribe(BusFilter{ObjectIds: []uint32{200}}, SubscriberOptions{})
+	failureSubscription := bus.Subscribe(BusFilter{EventKinds: []byte{PackageEventTypeFailureInfo}}, SubscriberOptions{})
+
+	assert.Nil(t, bus.Publish(&NetworkPackage{HostId: 2, Data: DataPackage{
+		Format: PackageFormatChangeObjectStates,
+		Data:   []byte{PackageEventTypeObjectState, 200, 0, 0, 0, 1, 0}}}))
+	assert.Nil(t, bus.Publish(&NetworkPackage{HostId: 1, Data: DataPackage{Format: PackageFormatHeartbeat}}))
+
+	assert.Len(t, hostSubscription.Messages(), 1)
+	message := <-hostSubscription.Messages()
+	assert.Equal(t, PackageFormatHeartbeat, message.Package.Data.Format)
+
+	assert.Len(t, objectSubscription.Messages(), 1)
+	message = <-objectSubscription.Messages()
+	assert.Equal(t, uint16(1), message.Events.ObjectStates[200])
+
+	assert.Len(t, failureSubscription.Messages(), 0)
+}
+
+func TestEventBusHostMeasurements(t *testing.T) {
+	bus := NewEventBus(nil)
+	defer bus.Close()
+
+	hostDevice, _ := NewSpecialDeviceId(1)
+	measurements := bus.Subscribe(BusFilter{Formats: []byte{PackageFormatData}}, SubscriberOptions{})
+	hostSubscription := bus.Subscribe(BusFilter{DeviceIds: []DeviceId{hostDevice}}, SubscriberOptions{})
+
+	assert.Nil(t, bus.Publish(&NetworkPackage{HostId: 1, Data: *makeMeasurePackage(hostDevice, time.Now(), 1000)}))
+	assert.Nil(t, bus.Publish(&NetworkPackage{HostId: 1, Data: *makeMeasurePackage(10, time.Now(), 1000)}))
+	assert.Nil(t, bus.Publish(&NetworkPackage{HostId: 1, Data: DataPackage{DeviceId: hostDevice, Format: PackageFormatHeartbeat}}))
+
+	assert.Len(t, measurements.Messages(), 1)
+	message := <-measurements.Messages()
+	assert.Equal(t, DeviceId(10), message.Package.Data.DeviceId)
+
+	assert.Len(t, hostSubscription.Messages(), 2)
+}
+
+func TestEventBusPolicies(t *testing.T) {
+	var slow []*Subscription
+	bus := NewEventBus(func(subscription *Subscription) {
+		slow = append(slow, subscription)
+	})
+
+	dropNewest := bus.Subscribe(BusFilter{}, SubscriberOptions{QueueSize: 1, Policy: BusPolicyDropNewest})
+	dropOldest := bus.Subscribe(BusFilter{}, SubscriberOptions{QueueSize: 1, Policy: BusPolicyDropOldest})
+	block := bus.Subscribe(BusFilter{}, SubscriberOptions{QueueSize: 1, Policy: BusPolicyBlock,
+		BlockTimeout: time.Millisecond})
+
+	bus.PublishMessage(&BusMessage{Package: &NetworkPackage{PackageId: 1}})
+	bus.PublishMessage(&BusMessage{Package: &NetworkPackage{PackageId: 2}})
+
+	assert.Len(t, slow, 3)
+
+	assert.Equal(t, uint64(1), dropNewest.Dropped())
+	assert.Equal(t, int32(1), (<-dropNewest.Messages()).Package.PackageId)
+
+	assert.Equal(t, uint64(1), dropOldest.Dropped())
+	assert.Equal(t, int32(2), (<-dropOldest.Messages()).Package.PackageId)
+
+	assert.Equal(t, uint64(1), block.Dropped())
+
+	bus.Close()
+
+	_, ok := <-block.Messages()
+	assert.True(t, ok)
+	_, ok = <-block.Messages()
+	assert.False(t, ok)
+}
+
+func TestEventBusStalledSubscriber(t *testing.T) {
+	bus := NewEventBus(nil)
+	stalled := bus.Subscribe(BusFilter{HostIds: []int32{1}}, SubscriberOptions{QueueSize: 1, Policy: BusPolicyBlock})
+
+	bus.PublishMessage(&BusMessage{Package: &NetworkPackage{HostId: 1, PackageId: 1}})
+
+	// Публикация ожидает освобождения очереди подписчика без ограничения времени
+	published := make(chan struct{})
+	go func() {
+		bus.PublishMessage(&BusMessage{Package: &NetworkPackage{HostId: 1, PackageId: 2}})
+		close(published)
+	}()
+
+	// Подписка и публикация другому подписчику не ожидают остановленного подписчика
+	other := bus.Subscribe(BusFilter{HostIds: []int32{5}}, SubscriberOptions{})
+	bus.PublishMessage(&BusMessage{Package: &NetworkPackage{HostId: 5, PackageId: 3}})
+	assert.Equal(t, int32(3), (<-other.Messages()).Package.PackageId)
+
+	stalled.Unsubscribe()
+	select {
+	case <-published:
+	case <-time.After(time.Second * 5):
+		assert.Fail(t, "publish was not interrupted by unsubscribe")
+	}
+
+	bus.Close()
+}
@@ -0,0 +1,182 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FailureInterval описывает завершившийся отказ объекта.
+// Если начало отказа не было получено (например, после переподключения),
+// то IsStartMissing = true и StartTime не определено.
+// Если завершение не было получено и отказ отсутствует в полном срезе,
+// то IsEndMissing = true и EndTime равно времени пакета полного среза.
+type FailureInterval struct {
+	HostId         int32
+	ObjectId       uint32
+	FailureId      uint32
+	StartTime      time.Time
+	EndTime        time.Time
+	IsStartMissing bool
+	IsEndMissing   bool
+}
+
+func (interval *FailureInterval) Duration() time.Duration {
+	if interval.IsStartMissing {
+		return 0
+	}
+	return interval.EndTime.Sub(interval.StartTime)
+}
+
+func (interval *FailureInterval) String() string {
+	return fmt.Sprintf("HostId=%d,ObjectId=%d,FailureId=%d,Start=%s,End=%s",
+		interval.HostId,
+		interval.ObjectId,
+		interval.FailureId,
+		interval.StartTime.Format(time.RFC3339Nano),
+		interval.EndTime.Format(time.RFC3339Nano))
+}
+
+type FailureIntervalHandler func(interval *FailureInterval)
+
+// FailureTracker сопоставляет начала и завершения отказов и выдает интервалы отказов
+type FailureTracker struct {
+	mutex    sync.Mutex
+	active   map[int32]map[ObjectFailureKey]*ObjectFailureEventInfo
+	onClosed FailureIntervalHandler
+}
+
+func NewFailureTracker(onClosed FailureIntervalHandler) *FailureTracker {
+	return &FailureTracker{
+		active:   make(map[int32]map[ObjectFailureKey]*ObjectFailureEventInfo),
+		onClosed: onClosed}
+}
+
+// Apply обрабатывает сетевой пакет. Пакеты без информации об отказах игнорируются.
+func (tracker *FailureTracker) Apply(networkPackage *NetworkPackage) error {
+	data := &networkPackage.Data
+
+	switch data.Format {
+	case PackageFormatFullFailureStates:
+		fullState, err := data.ParseFullFailureStatePackage()
+		if err != nil {
+			return err
+		}
+		tracker.ApplyFullState(networkPackage.HostId, fullState, data.GetPackageTime())
+	case PackageFormatEvents, PackageFormatChangeFailureStates:
+		events, err := data.ParseEventsPackage()
+		if err != nil {
+			return err
+		}
+		tracker.ApplyEvents(networkPackage.HostId, events.ObjectFailuresChangeState)
+	}
+	return nil
+}
+
+func (tracker *FailureTracker) ApplyEvents(hostId int32, events map[ObjectFailureKey]*ObjectFailureEventInfo) {
+	var closed []*FailureInterval
+
+	tracker.mutex.Lock()
+	active := tracker.getHostFailures(hostId)
+
+	for key, event := range events {
+		started, isActive := active[key]
+
+		if event.IsStarted {
+			if !isActive {
+				active[key] = event
+			}
+			continue
+		}
+
+		interval := &FailureInterval{
+			HostId:    hostId,
+			ObjectId:  key.ObjectId,
+			FailureId: key.FailureId,
+			EndTime:   event.EventTime}
+
+		if isActive {
+			interval.StartTime = started.EventTime
+			delete(active, key)
+		} else {
+			interval.IsStartMissing = true
+		}
+		closed = append(closed, interval)
+	}
+	tracker.mutex.Unlock()
+
+	tracker.notify(closed)
+}
+
+// ApplyFullState сверяет активные отказы хоста с полным срезом: отказы, отсутствующие
+// в срезе, закрываются временем среза, а новые отказы из среза становятся активными.
+func (tracker *FailureTracker) ApplyFullState(hostId int32,
+	fullState map[ObjectFailureKey]*ObjectFailureEventInfo, stateTime time.Time) {
+
+	var closed []*FailureInterval
+
+	tracker.mutex.Lock()
+	active := tracker.getHostFailures(hostId)
+
+	for key, started := range active {
+		if event, ok := fullState[key]; ok && event.IsStarted {
+			continue
+		}
+		closed = append(closed, &FailureInterval{
+			HostId:       hostId,
+			ObjectId:     key.ObjectId,
+			FailureId:    key.FailureId,
+			StartTime:    started.EventTime,
+			EndTime:      stateTime,
+			IsEndMissing: true})
+		delete(active, key)
+	}
+
+	for key, event := range fullState {
+		if _, ok := active[key]; !ok && event.IsStarted {
+			active[key] = event
+		}
+	}
+	tracker.mutex.Unlock()
+
+	tracker.notify(closed)
+}
+
+func (tracker *FailureTracker) IsActive(hostId int32, key ObjectFailureKey) bool {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	_, ok := tracker.active[hostId][key]
+	return ok
+}
+
+// GetActiveFailures возвращает копию активных отказов хоста
+func (tracker *FailureTracker) GetActiveFailures(hostId int32) map[ObjectFailureKey]*ObjectFailureEventInfo {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	result := make(map[ObjectFailureKey]*ObjectFailureEventInfo, len(tracker.active[hostId]))
+	for key, event := range tracker.active[hostId] {
+		eventCopy := *event
+		result[key] = &eventCopy
+	}
+	return result
+}
+
+func (tracker *FailureTracker) getHostFailures(hostId int32) map[ObjectFailureKey]*ObjectFailureEventInfo {
+	active, ok := tracker.active[hostId]
+	if !ok {
+		active = make(map[ObjectFailureKey]*ObjectFailureEventInfo)
+		tracker.active[hostId] = active
+	}
+	return active
+}
+
+func (tracker *FailureTracker) notify(closed []*FailureInterval) {
+	if tracker.onClosed == nil {
+		return
+	}
+	for _, interval := range closed {
+		tracker.onClosed(interval)
+	}
+}
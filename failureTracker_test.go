@@ -0,0 +1,65 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFailureTrackerEvents(t *testing.T) {
+	startTime := time.Now().Add(-time.Minute)
+	endTime := startTime.Add(time.Second * 30)
+	key := ObjectFailureKey{ObjectId: 100, FailureId: 1}
+
+	var closed []*FailureInterval
+	tracker := NewFailureTracker(func(interval *FailureInterval) {
+		closed = append(closed, interval)
+	})
+
+	tracker.ApplyEvents(1, map[ObjectFailureKey]*ObjectFailureEventInfo{
+		key: {ObjectId: 100, FailureId: 1, IsStarted: true, EventTime: startTime}})
+	assert.True(t, tracker.IsActive(1, key))
+	assert.False(t, tracker.IsActive(2, key))
+	assert.Empty(t, closed)
+
+	tracker.ApplyEvents(1, map[ObjectFailureKey]*ObjectFailureEventInfo{
+		key: {ObjectId: 100, FailureId: 1, IsStarted: false, EventTime: endTime}})
+	assert.False(t, tracker.IsActive(1, key))
+	assert.Len(t, closed, 1)
+	assert.Equal(t, time.Second*30, closed[0].Duration())
+	assert.False(t, closed[0].IsStartMissing)
+
+	// Завершение без начала
+	tracker.ApplyEvents(1, map[ObjectFailureKey]*ObjectFailureEventInfo{
+		key: {ObjectId: 100, FailureId: 1, IsStarted: false, EventTime: endTime}})
+	assert.Len(t, closed, 2)
+	assert.True(t, closed[1].IsStartMissing)
+	assert.Equal(t, time.Duration(0), closed[1].Duration())
+}
+
+func TestFailureTrackerFullState(t *testing.T) {
+	startTime := time.Now().Add(-time.Minute)
+	stateTime := time.Now()
+	key1 := ObjectFailureKey{ObjectId: 100, FailureId: 1}
+	key2 := ObjectFailureKey{ObjectId: 200, FailureId: 2}
+
+	var closed []*FailureInterval
+	tracker := NewFailureTracker(func(interval *FailureInterval) {
+		closed = append(closed, interval)
+	})
+
+	tracker.ApplyEvents(1, map[ObjectFailureKey]*ObjectFailureEventInfo{
+		key1: {ObjectId: 100, FailureId: 1, IsStarted: true, EventTime: startTime}})
+
+	tracker.ApplyFullState(1, map[ObjectFailureKey]*ObjectFailureEventInfo{
+		key2: {ObjectId: 200, FailureId: 2, IsStarted: true, EventTime: startTime}}, stateTime)
+
+	assert.Len(t, closed, 1)
+	assert.Equal(t, key1, ObjectFailureKey{ObjectId: closed[0].ObjectId, FailureId: closed[0].FailureId})
+	assert.True(t, closed[0].IsEndMissing)
+	assert.Equal(t, stateTime, closed[0].EndTime)
+
+	active := tracker.GetActiveFailures(1)
+	assert.Len(t, active, 1)
+	assert.Contains(t, active, key2)
+}
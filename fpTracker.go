@@ -0,0 +1,196 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+type FpKey struct {
+	ObjectId    uint32
+	AlgorithmId uint32
+}
+
+// FpStep - шаг алгоритма прогнозирования отказа и время перехода на него
+type FpStep struct {
+	StepIndex int32
+	StartTime time.Time
+}
+
+type FpStepDuration struct {
+	StepIndex int32
+	Duration  time.Duration
+}
+
+// FpProgress - ход выполнения алгоритма прогнозирования отказа для объекта
+type FpProgress struct {
+	Key         FpKey
+	Steps       []FpStep // шаги текущего прохода алгоритма в порядке времени
+	IsCompleted bool     // алгоритм дошел до последнего шага
+}
+
+func (progress *FpProgress) CurrentStep() (FpStep, bool) {
+	if len(progress.Steps) == 0 {
+		return FpStep{}, false
+	}
+	return progress.Steps[len(progress.Steps)-1], true
+}
+
+// GetStepDurations возвращает время нахождения на каждом шаге,
+// для текущего шага - время до момента now
+func (progress *FpProgress) GetStepDurations(now time.Time) []FpStepDuration {
+	result := make([]FpStepDuration, 0, len(progress.Steps))
+	for i, step := range progress.Steps {
+		endTime := now
+		if i+1 < len(progress.Steps) {
+			endTime = progress.Steps[i+1].StartTime
+		}
+		result = append(result, FpStepDuration{StepIndex: step.StepIndex, Duration: endTime.Sub(step.StartTime)})
+	}
+	return result
+}
+
+func (progress *FpProgress) copy() *FpProgress {
+	result := *progress
+	result.Steps = append([]FpStep(nil), progress.Steps...)
+	return &result
+}
+
+const (
+	FpProgressStepChanged byte = 1 // переход на следующий шаг
+	FpProgressReset       byte = 2 // алгоритм сброшен (шаг уменьшился или отрицательный)
+	FpProgressCompleted   byte = 3 // алгоритм дошел до последнего шага
+)
+
+// FpProgressEvent передается в обработчик при изменении хода алгоритма.
+// При сбросе Previous содержит завершившийся проход алгоритма.
+type FpProgressEvent struct {
+	HostId   int32
+	Kind     byte
+	Progress *FpProgress
+	Previous *FpProgress
+}
+
+type FpProgressHandler func(event *FpProgressEvent)
+
+// FpTracker хранит историю шагов алгоритмов прогнозирования отказов для каждого объекта
+type FpTracker struct {
+	mutex      sync.RWMutex
+	hosts      map[int32]map[FpKey]*FpProgress
+	stepCounts map[uint32]int32
+	onProgress FpProgressHandler
+}
+
+func NewFpTracker(onProgress FpProgressHandler) *FpTracker {
+	return &FpTracker{
+		hosts:      make(map[int32]map[FpKey]*FpProgress),
+		stepCounts: make(map[uint32]int32),
+		onProgress: onProgress}
+}
+
+// SetAlgorithmStepCount задает количество шагов алгоритма, необходимое для определения его завершения
+func (tracker *FpTracker) SetAlgorithmStepCount(algorithmId uint32, stepCount int32) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	tracker.stepCounts[algorithmId] = stepCount
+}
+
+// Apply обрабатывает сетевой пакет. Пакеты без событий игнорируются.
+func (tracker *FpTracker) Apply(networkPackage *NetworkPackage) error {
+	if networkPackage.Data.Format != PackageFormatEvents {
+		return nil
+	}
+
+	events, err := networkPackage.Data.ParseEventsPackage()
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events.ObjectFpChangeState {
+		tracker.ApplyEvent(networkPackage.HostId, event)
+	}
+	return nil
+}
+
+func (tracker *FpTracker) ApplyEvent(hostId int32, event *ObjectFpEventInfo) {
+	tracker.mutex.Lock()
+
+	host, ok := tracker.hosts[hostId]
+	if !ok {
+		host = make(map[FpKey]*FpProgress)
+		tracker.hosts[hostId] = host
+	}
+
+	key := FpKey{ObjectId: event.ObjectId, AlgorithmId: event.AlgorithmId}
+	progress, ok := host[key]
+	if !ok {
+		progress = &FpProgress{Key: key}
+		host[key] = progress
+	}
+
+	result := &FpProgressEvent{HostId: hostId}
+
+	current, hasCurrent := progress.CurrentStep()
+	switch {
+	case hasCurrent && current.StepIndex == event.StepIndex:
+		tracker.mutex.Unlock()
+		return
+	case event.StepIndex < 0 && !hasCurrent:
+		// Сброс алгоритма, который не выполнялся
+		if !ok {
+			delete(host, key)
+		}
+		tracker.mutex.Unlock()
+		return
+	case event.StepIndex < 0 || (hasCurrent && event.StepIndex < current.StepIndex):
+		result.Kind = FpProgressReset
+		result.Previous = progress.copy()
+		progress.Steps = nil
+		progress.IsCompleted = false
+	default:
+		result.Kind = FpProgressStepChanged
+	}
+
+	if event.StepIndex >= 0 {
+		progress.Steps = append(progress.Steps, FpStep{StepIndex: event.StepIndex, StartTime: event.EventTime})
+	}
+
+	if stepCount, ok := tracker.stepCounts[event.AlgorithmId]; ok && event.StepIndex >= stepCount-1 {
+		progress.IsCompleted = true
+		if result.Kind == FpProgressStepChanged {
+			result.Kind = FpProgressCompleted
+		}
+	}
+
+	result.Progress = progress.copy()
+	tracker.mutex.Unlock()
+
+	if tracker.onProgress != nil {
+		tracker.onProgress(result)
+	}
+}
+
+func (tracker *FpTracker) GetProgress(hostId int32, key FpKey) (*FpProgress, bool) {
+	tracker.mutex.RLock()
+	defer tracker.mutex.RUnlock()
+
+	progress, ok := tracker.hosts[hostId][key]
+	if !ok {
+		return nil, false
+	}
+	return progress.copy(), true
+}
+
+// GetObjectProgress возвращает ход всех алгоритмов прогнозирования для объекта
+func (tracker *FpTracker) GetObjectProgress(hostId int32, objectId uint32) []*FpProgress {
+	tracker.mutex.RLock()
+	defer tracker.mutex.RUnlock()
+
+	var result []*FpProgress
+	for key, progress := range tracker.hosts[hostId] {
+		if key.ObjectId == objectId {
+			result = append(result, progress.copy())
+		}
+	}
+	return result
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFpTracker(t *testing.T) {
+	now := time.Now()
+	key := FpKey{ObjectId: 100, AlgorithmId: 4}
+
+	var events []*FpProgressEvent
+	tracker := NewFpTracker(func(event *FpProgressEvent) {
+		events = append(events, event)
+	})
+	tracker.SetAlgorithmStepCount(4, 3)
+
+	tracker.ApplyEvent(1, &ObjectFpEventInfo{ObjectId: 100, AlgorithmId: 4, StepIndex: 0, EventTime: now.Add(-time.Minute * 10)})
+	tracker.ApplyEvent(1, &ObjectFpEventInfo{ObjectId: 100, AlgorithmId: 4, StepIndex: 1, EventTime: now.Add(-time.Minute * 4)})
+	tracker.ApplyEvent(1, &ObjectFpEventInfo{ObjectId: 100, AlgorithmId: 4, StepIndex: 1, EventTime: now.Add(-time.Minute * 3)})
+
+	assert.Len(t, events, 2)
+	assert.Equal(t, FpProgressStepChanged, events[1].Kind)
+
+	progress, ok := tracker.GetProgress(1, key)
+	assert.True(t, ok)
+	step, ok := progress.CurrentStep()
+	assert.True(t, ok)
+	assert.Equal(t, int32(1), step.StepIndex)
+	assert.Equal(t, []FpStepDuration{
+		{StepIndex: 0, Duration: time.Minute * 6},
+		{StepIndex: 1, Duration: time.Minute * 4}}, progress.GetStepDurations(now))
+
+	tracker.ApplyEvent(1, &ObjectFpEventInfo{ObjectId: 100, AlgorithmId: 4, StepIndex: 2, EventTime: now})
+	assert.Equal(t, FpProgressCompleted, events[2].Kind)
+	assert.True(t, events[2].Progress.IsCompleted)
+
+	tracker.ApplyEvent(1, &ObjectFpEventInfo{ObjectId: 100, AlgorithmId: 4, StepIndex: 0, EventTime: now})
+	assert.Equal(t, FpProgressReset, events[3].Kind)
+	assert.Len(t, events[3].Previous.Steps, 3)
+	assert.Len(t, events[3].Progress.Steps, 1)
+	assert.False(t, events[3].Progress.IsCompleted)
+
+	assert.Len(t, tracker.GetObjectProgress(1, 100), 1)
+	assert.Empty(t, tracker.GetObjectProgress(2, 100))
+}
+
+func TestFpTrackerRepeatedReset(t *testing.T) {
+	now := time.Now()
+
+	var events []*FpProgressEvent
+	tracker := NewFpTracker(func(event *FpProgressEvent) {
+		events = append(events, event)
+	})
+
+	tracker.ApplyEvent(1, &ObjectFpEventInfo{ObjectId: 100, AlgorithmId: 4, StepIndex: 0, EventTime: now})
+	tracker.ApplyEvent(1, &ObjectFpEventInfo{ObjectId: 100, AlgorithmId: 4, StepIndex: -1, EventTime: now})
+	tracker.ApplyEvent(1, &ObjectFpEventInfo{ObjectId: 100, AlgorithmId: 4, StepIndex: -1, EventTime: now})
+	tracker.ApplyEvent(1, &ObjectFpEventInfo{ObjectId: 200, AlgorithmId: 4, StepIndex: -1, EventTime: now})
+
+	assert.Len(t, events, 2)
+	assert.Equal(t, FpProgressReset, events[1].Kind)
+	assert.Empty(t, tracker.GetObjectProgress(1, 200))
+}
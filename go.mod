@@ -6,4 +6,5 @@ require (
 	github.com/deckarep/golang-set v1.8.0
 	github.com/stretchr/testify v1.8.0
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1
+	gopkg.in/yaml.v3 v3.0.1
 )
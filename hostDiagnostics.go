@@ -0,0 +1,71 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// HostDiagnostics - диагностика хоста из пакета специального устройства хоста.
+// Заполняются только поля, соответствующие формату пакета. PackageTime - время пакета,
+// сформированного хостом, отдельного поля времени хоста в пакетах нет.
+type HostDiagnostics struct {
+	HostId               int
+	Format               byte
+	PackageTime          time.Time
+	NotRespondingDevices []DeviceId                                // текущий список неотвечающих устройств хоста
+	DeviceConnections    map[DeviceId]*DeviceNoConnectionEventInfo // события потери и восстановления связи с устройствами
+	Failures             map[ObjectFailureKey]*ObjectFailureEventInfo
+	IsFullFailureState   bool // Failures содержит полное состояние отказов хоста
+}
+
+// IsHostPackage возвращает true для пакетов специального устройства хоста
+func (data *DataPackage) IsHostPackage() bool {
+	return data.DeviceId.IsSpecial()
+}
+
+func (res *NetworkPackage) IsHostPackage() bool {
+	return res.Data.IsHostPackage()
+}
+
+// ParseHostDiagnostics разбирает пакет специального устройства хоста
+func (data *DataPackage) ParseHostDiagnostics() (*HostDiagnostics, error) {
+	hostId, err := data.DeviceId.Host()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &HostDiagnostics{
+		HostId:            hostId,
+		Format:            data.Format,
+		PackageTime:       data.GetPackageTime(),
+		DeviceConnections: make(map[DeviceId]*DeviceNoConnectionEventInfo),
+		Failures:          make(map[ObjectFailureKey]*ObjectFailureEventInfo)}
+
+	switch data.Format {
+	case PackageFormatHeartbeat:
+
+	case PackageFormatChangeNotRespondingDevices:
+		if result.NotRespondingDevices, err = data.ParseNotRespondingDevicesPackage(); err != nil {
+			return nil, err
+		}
+
+	case PackageFormatFullFailureStates:
+		if result.Failures, err = data.ParseFullFailureStatePackage(); err != nil {
+			return nil, err
+		}
+		result.IsFullFailureState = true
+
+	case PackageFormatEvents, PackageFormatChangeFailureStates:
+		events, err := data.ParseEventsPackage()
+		if err != nil {
+			return nil, err
+		}
+		result.DeviceConnections = events.DevicesNoConnection
+		result.Failures = events.ObjectFailuresChangeState
+
+	default:
+		return nil, fmt.Errorf("unsupported host package format %d", data.Format)
+	}
+
+	return result, nil
+}
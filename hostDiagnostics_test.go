@@ -0,0 +1,58 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseHostDiagnostics(t *testing.T) {
+	hostDevice, err := NewSpecialDeviceId(3)
+	assert.Nil(t, err)
+
+	packageTime, timeSlice := getTimeAndSlice(time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC))
+
+	heartbeat := &DataPackage{DeviceId: hostDevice, Format: PackageFormatHeartbeat,
+		Time: uint64(GetUnixMicrosecondsFromTime(packageTime))}
+	assert.True(t, heartbeat.IsHostPackage())
+
+	diagnostics, err := heartbeat.ParseHostDiagnostics()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, diagnostics.HostId)
+	assert.True(t, packageTime.Equal(diagnostics.PackageTime))
+
+	diagnostics, err = (&DataPackage{DeviceId: hostDevice, Format: PackageFormatChangeNotRespondingDevices,
+		Data: []byte{10, 0, 0, 0}}).ParseHostDiagnostics()
+	assert.Nil(t, err)
+	assert.Equal(t, []DeviceId{10}, diagnostics.NotRespondingDevices)
+
+	events := append([]byte{PackageEventTypeNoConnectionWithDevice, 10, 0, 0, 0, 1, 0, 0, 0, 1}, timeSlice...)
+	events = append(events, PackageEventTypeFailureInfo, 100, 0, 0, 0, 1, 0, 0, 0, 1)
+	events = append(events, timeSlice...)
+
+	diagnostics, err = (&DataPackage{DeviceId: hostDevice, Format: PackageFormatEvents, Data: events}).ParseHostDiagnostics()
+	assert.Nil(t, err)
+	assert.Len(t, diagnostics.DeviceConnections, 1)
+	assert.True(t, diagnostics.DeviceConnections[10].IsStarted)
+	assert.Len(t, diagnostics.Failures, 1)
+	assert.False(t, diagnostics.IsFullFailureState)
+
+	_, err = (&DataPackage{DeviceId: hostDevice, Format: PackageFormatData}).ParseHostDiagnostics()
+	assert.NotNil(t, err)
+
+	// Пакет полевого устройства
+	_, err = (&DataPackage{DeviceId: 10, Format: PackageFormatHeartbeat}).ParseHostDiagnostics()
+	assert.NotNil(t, err)
+}
+
+func TestHostPackageMeasurements(t *testing.T) {
+	hostDevice, _ := NewSpecialDeviceId(3)
+	data := makeMeasurePackage(hostDevice, time.Now(), 1000)
+
+	aggregator, err := NewAggregator(time.Minute, nil)
+	assert.Nil(t, err)
+	assert.NotNil(t, aggregator.AddPackage(data))
+
+	_, err = data.GetMeasurements(nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LogField - именованное значение, добавляемое к сообщению лога
+type LogField struct {
+	Key   string
+	Value interface{}
+}
+
+func Field(key string, value interface{}) LogField {
+	return LogField{Key: key, Value: value}
+}
+
+func HostIdField(hostId int32) LogField {
+	return LogField{Key: "hostId", Value: hostId}
+}
+
+func DeviceIdField(deviceId DeviceId) LogField {
+	return LogField{Key: "deviceId", Value: deviceId}
+}
+
+func ErrorField(err error) LogField {
+	return LogField{Key: "error", Value: err}
+}
+
+// appendLogFields возвращает новый срез, чтобы дочерние логгеры не разделяли поля
+func appendLogFields(fields []LogField, added []LogField) []LogField {
+	if len(added) == 0 {
+		return fields
+	}
+	result := make([]LogField, 0, len(fields)+len(added))
+	result = append(result, fields...)
+	return append(result, added...)
+}
+
+// replaceLogField возвращает новый срез, в котором поле с ключом field.Key заменено на field
+// или добавлено, если такого поля нет
+func replaceLogField(fields []LogField, field LogField) []LogField {
+	result := make([]LogField, 0, len(fields)+1)
+	for _, existing := range fields {
+		if existing.Key != field.Key {
+			result = append(result, existing)
+		}
+	}
+	return append(result, field)
+}
+
+// formatTextFields форматирует поля как " key=value key2=value2"
+func formatTextFields(fields []LogField) string {
+	var builder strings.Builder
+	for _, field := range fields {
+		builder.WriteByte(' ')
+		builder.WriteString(field.Key)
+		builder.WriteByte('=')
+
+		value := formatLogFieldValue(field.Value)
+		if value == "" || strings.ContainsAny(value, " =\"\t\n") {
+			value = strconv.Quote(value)
+		}
+		builder.WriteString(value)
+	}
+	return builder.String()
+}
+
+func formatLogFieldValue(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return typed
+	case error:
+		return typed.Error()
+	case fmt.Stringer:
+		return typed.String()
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// formatJsonLine формирует JSON-объект сообщения: служебные поля, затем поля сообщения в порядке добавления
+func formatJsonLine(timeValue string, level string, caller string, message string, fields []LogField) string {
+	var buffer bytes.Buffer
+
+	buffer.WriteString(`{"time":`)
+	writeJsonValue(&buffer, timeValue)
+	buffer.WriteString(`,"level":`)
+	writeJsonValue(&buffer, level)
+	if caller != "" {
+		buffer.WriteString(`,"caller":`)
+		writeJsonValue(&buffer, caller)
+	}
+	buffer.WriteString(`,"message":`)
+	writeJsonValue(&buffer, message)
+
+	for _, field := range fields {
+		buffer.WriteByte(',')
+		writeJsonValue(&buffer, field.Key)
+		buffer.WriteByte(':')
+
+		if err, ok := field.Value.(error); ok {
+			writeJsonValue(&buffer, err.Error())
+		} else {
+			writeJsonValue(&buffer, field.Value)
+		}
+	}
+
+	buffer.WriteByte('}')
+	return buffer.String()
+}
+
+func writeJsonValue(buffer *bytes.Buffer, value interface{}) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		content, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buffer.Write(content)
+}
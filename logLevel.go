@@ -0,0 +1,77 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+type LogLevel int
+
+const (
+	LogLevelError   LogLevel = 0
+	LogLevelWarning LogLevel = 1
+	LogLevelInfo    LogLevel = 2
+	LogLevelDebug   LogLevel = 3
+	LogLevelTrace   LogLevel = 4
+)
+
+func (level LogLevel) String() string {
+	switch level {
+	case LogLevelError:
+		return "ERROR"
+	case LogLevelWarning:
+		return "WARNING"
+	case LogLevelInfo:
+		return "INFO"
+	case LogLevelDebug:
+		return "DEBUG"
+	case LogLevelTrace:
+		return "TRACE"
+	default:
+		return fmt.Sprintf("LEVEL%d", int(level))
+	}
+}
+
+// ParseLogLevel возвращает уровень по имени без учета регистра, например "debug"
+func ParseLogLevel(name string) (LogLevel, error) {
+	for level := LogLevelError; level <= LogLevelTrace; level++ {
+		if strings.EqualFold(name, level.String()) {
+			return level, nil
+		}
+	}
+	return LogLevelInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+// logLevels - уровни логгера, общие для логгера и всех дочерних логгеров
+type logLevels struct {
+	mutex      sync.RWMutex
+	level      LogLevel
+	components map[string]LogLevel
+}
+
+func newLogLevels(level LogLevel) *logLevels {
+	return &logLevels{level: level, components: make(map[string]LogLevel)}
+}
+
+// getLevel возвращает уровень компонента, а если он не задан - общий уровень
+func (levels *logLevels) getLevel(component string) LogLevel {
+	levels.mutex.RLock()
+	defer levels.mutex.RUnlock()
+
+	if level, ok := levels.components[component]; ok && component != "" {
+		return level
+	}
+	return levels.level
+}
+
+func (levels *logLevels) setLevel(component string, level LogLevel) {
+	levels.mutex.Lock()
+	defer levels.mutex.Unlock()
+
+	if component == "" {
+		levels.level = level
+	} else {
+		levels.components[component] = level
+	}
+}
@@ -0,0 +1,244 @@
+package core
+
+import (
+	"fmt"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogEntry - сообщение лога. Fields содержит поля логгера и поля сообщения.
+type LogEntry struct {
+	Time    time.Time
+	Level   LogLevel
+	Caller  string // файл и строка вызова, заполняется для ошибок
+	Message string
+	Fields  []LogField
+}
+
+// Text возвращает сообщение в текстовом виде без префикса уровня
+func (entry *LogEntry) Text() string {
+	if entry.Caller != "" {
+		return fmt.Sprintf("%s %s", entry.Time.Format("15:04:05.000"), entry.messageText())
+	}
+	return fmt.Sprintf("%s: %s", entry.Time.Format("15:04:05.000"), entry.messageText())
+}
+
+// messageText возвращает сообщение с местом вызова и полями, но без времени
+func (entry *LogEntry) messageText() string {
+	if entry.Caller != "" {
+		return fmt.Sprintf("%s: %s%s", entry.Caller, entry.Message, formatTextFields(entry.Fields))
+	}
+	return entry.Message + formatTextFields(entry.Fields)
+}
+
+// Json возвращает сообщение в виде JSON-объекта
+func (entry *LogEntry) Json() string {
+	return formatJsonLine(entry.Time.Format(time.RFC3339Nano), entry.Level.String(), entry.Caller, entry.Message, entry.Fields)
+}
+
+// LogOutput - вывод сообщений лога. Логгер передает в вывод сообщения с уровнем не выше MinLevel.
+type LogOutput interface {
+	MinLevel() LogLevel
+	Write(entry *LogEntry) error
+	Close() error
+}
+
+// RotatableLogOutput - вывод, поддерживающий начало нового файла
+type RotatableLogOutput interface {
+	LogOutput
+	Rotate() error
+}
+
+type LogOutputOptions struct {
+	MinLevel   LogLevel
+	JsonOutput bool // JSON-объект в строке вместо текста с префиксом уровня
+}
+
+// writerLogOutput выводит сообщения в формате log.Logger с префиксом уровня
+type writerLogOutput struct {
+	options LogOutputOptions
+	loggers map[LogLevel]*log.Logger
+	closer  io.Closer
+}
+
+var logLevelPrefixes = map[LogLevel]string{
+	LogLevelError:   ErrorPrefix,
+	LogLevelWarning: WarningPrefix,
+	LogLevelInfo:    InfoPrefix,
+	LogLevelDebug:   DebugPrefix,
+	LogLevelTrace:   TracePrefix,
+}
+
+func newWriterLogOutput(options LogOutputOptions, writer io.Writer, errorWriter io.Writer, closer io.Closer) *writerLogOutput {
+	result := &writerLogOutput{options: options, loggers: make(map[LogLevel]*log.Logger), closer: closer}
+
+	for level, prefix := range logLevelPrefixes {
+		output := writer
+		if level == LogLevelError {
+			output = errorWriter
+		}
+
+		if options.JsonOutput {
+			// Время и уровень записываются в JSON-объект
+			result.loggers[level] = log.New(output, "", 0)
+		} else {
+			// Дата и время формируются из времени сообщения, а не из системных часов
+			result.loggers[level] = log.New(output, prefix, 0)
+		}
+	}
+
+	return result
+}
+
+// NewWriterLogOutput возвращает вывод в writer
+func NewWriterLogOutput(writer io.Writer, options LogOutputOptions) LogOutput {
+	return newWriterLogOutput(options, writer, writer, nil)
+}
+
+// NewConsoleLogOutput возвращает вывод в stdout, ошибки выводятся в stderr
+func NewConsoleLogOutput(options LogOutputOptions) LogOutput {
+	return newWriterLogOutput(options, os.Stdout, os.Stderr, nil)
+}
+
+func (output *writerLogOutput) MinLevel() LogLevel {
+	return output.options.MinLevel
+}
+
+func (output *writerLogOutput) Write(entry *LogEntry) error {
+	logger, ok := output.loggers[entry.Level]
+	if !ok {
+		logger = output.loggers[LogLevelTrace]
+	}
+
+	if output.options.JsonOutput {
+		return logger.Output(2, entry.Json())
+	}
+	return logger.Output(2, entry.Time.Format("2006/01/02 ")+entry.Text())
+}
+
+func (output *writerLogOutput) Close() error {
+	if output.closer == nil {
+		return nil
+	}
+	return output.closer.Close()
+}
+
+type rollFileLogOutput struct {
+	*writerLogOutput
+	rollFile *lumberjack.Logger
+}
+
+// NewRollFileLogOutput возвращает вывод в файл с ротацией
+func NewRollFileLogOutput(fileName string, options LogOutputOptions, rollOptions RollFileOptions) (RotatableLogOutput, error) {
+	fileMode := rollOptions.FileMode
+	if fileMode == 0 {
+		fileMode = 0666
+	}
+
+	// Проверяем что файл для лога можно открыть или создать
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, fileMode)
+
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	file.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Права нового файла ограничены umask, ротация сохраняет права текущего файла
+	if rollOptions.FileMode != 0 && info.Mode().Perm() != fileMode.Perm() {
+		if err = os.Chmod(fileName, fileMode); err != nil {
+			return nil, err
+		}
+	}
+
+	var rollLogger = &lumberjack.Logger{
+		Filename:   fileName,
+		MaxSize:    rollOptions.MaxSize, // megabytes
+		MaxBackups: rollOptions.MaxBackups,
+		MaxAge:     rollOptions.MaxAge, //days
+		Compress:   rollOptions.Compress,
+		LocalTime:  rollOptions.LocalTime,
+	}
+
+	if rollOptions.RotateOnStart && info.Size() > 0 {
+		if err = rollLogger.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &rollFileLogOutput{
+		writerLogOutput: newWriterLogOutput(options, rollLogger, rollLogger, rollLogger),
+		rollFile:        rollLogger}, nil
+}
+
+func (output *rollFileLogOutput) Rotate() error {
+	return output.rollFile.Rotate()
+}
+
+// MemoryLogOutput хранит последние сообщения в памяти, например для отображения в диагностике
+type MemoryLogOutput struct {
+	mutex    sync.Mutex
+	minLevel LogLevel
+	entries  []LogEntry
+	next     int
+	isFull   bool
+}
+
+func NewMemoryLogOutput(minLevel LogLevel, capacity int) *MemoryLogOutput {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &MemoryLogOutput{minLevel: minLevel, entries: make([]LogEntry, capacity)}
+}
+
+func (output *MemoryLogOutput) MinLevel() LogLevel {
+	return output.minLevel
+}
+
+func (output *MemoryLogOutput) Write(entry *LogEntry) error {
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+
+	output.entries[output.next] = *entry
+	output.next = (output.next + 1) % len(output.entries)
+	if output.next == 0 {
+		output.isFull = true
+	}
+	return nil
+}
+
+func (output *MemoryLogOutput) Close() error {
+	return nil
+}
+
+// Entries возвращает хранимые сообщения от старых к новым
+func (output *MemoryLogOutput) Entries() []LogEntry {
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+
+	if !output.isFull {
+		return append([]LogEntry(nil), output.entries[:output.next]...)
+	}
+	result := append([]LogEntry(nil), output.entries[output.next:]...)
+	return append(result, output.entries[:output.next]...)
+}
+
+// Lines возвращает хранимые сообщения в текстовом виде от старых к новым
+func (output *MemoryLogOutput) Lines() []string {
+	entries := output.Entries()
+
+	result := make([]string, 0, len(entries))
+	for i := range entries {
+		result = append(result, entries[i].Level.String()+": "+entries[i].Text())
+	}
+	return result
+}
@@ -2,10 +2,29 @@ package core
 
 type Logger interface {
 	Clear()
-	Info(message string)
-	Error(message string)
-	Warning(message string)
-	Trace(message string)
+	Info(message string, fields ...LogField)
+	Error(message string, fields ...LogField)
+	Warning(message string, fields ...LogField)
+	Debug(message string, fields ...LogField)
+	Trace(message string, fields ...LogField)
+	// Deprecated: use IsEnabled(LogLevelTrace)
 	IsTraceEnabled() bool
-	FatalError(message string)
+	// IsEnabled возвращает true, если сообщения уровня level выводятся
+	IsEnabled(level LogLevel) bool
+	SetLevel(level LogLevel)
+	// SetComponentLevel задает уровень логгеров компонента name
+	SetComponentLevel(name string, level LogLevel)
+	FatalError(message string, fields ...LogField)
+	// With возвращает логгер, добавляющий fields ко всем сообщениям
+	With(fields ...LogField) Logger
+	// Component возвращает логгер компонента с отдельно задаваемым уровнем
+	Component(name string) Logger
+}
+
+// RotatableLogger - логгер, поддерживающий начало новых файлов лога.
+// Реализуется логгерами, созданными InitLogging и связанными функциями.
+type RotatableLogger interface {
+	Logger
+	// Rotate начинает новый файл лога, если логгер пишет в файл
+	Rotate() error
 }
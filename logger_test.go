@@ -0,0 +1,210 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readLogLines возвращает строки файла лога без завершающей пустой строки
+func readLogLines(t *testing.T, fileName string) []string {
+	content, err := ioutil.ReadFile(fileName)
+	assert.Nil(t, err)
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
+func TestStructuredLogging(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "test.log")
+	clock := NewManualClock(time.Date(2020, 1, 1, 10, 20, 30, 0, time.Local))
+
+	logger, err := InitRollFileLogging(fileName, false, WithClock(clock))
+	assert.Nil(t, err)
+
+	hostLogger := logger.With(HostIdField(1))
+	hostLogger.Info("package received", DeviceIdField(10), Field("format", "data package"))
+	hostLogger.Warning("no fields")
+	logger.Info("parent")
+	logger.Clear()
+
+	lines := readLogLines(t, fileName)
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasSuffix(lines[0], `10:20:30.000: package received hostId=1 deviceId=10 format="data package"`))
+	assert.True(t, strings.HasSuffix(lines[1], "10:20:30.000: no fields hostId=1"))
+	assert.True(t, strings.HasSuffix(lines[2], "10:20:30.000: parent"))
+}
+
+func TestJsonLogging(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "test.log")
+	clock := NewManualClock(time.Date(2020, 1, 1, 10, 20, 30, 0, time.UTC))
+
+	logger, err := InitRollFileLogging(fileName, false, WithClock(clock), WithJsonOutput())
+	assert.Nil(t, err)
+
+	logger.With(HostIdField(1)).Error("write failed", DeviceIdField(10), ErrorField(errors.New("disk full")))
+	logger.Clear()
+
+	lines := readLogLines(t, fileName)
+	assert.Len(t, lines, 1)
+	assert.True(t, strings.HasPrefix(lines[0], `{"time":"2020-01-01T10:20:30Z","level":"ERROR","caller":"logger_test.go:`))
+
+	var entry map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "write failed", entry["message"])
+	assert.Equal(t, 1.0, entry["hostId"])
+	assert.Equal(t, 10.0, entry["deviceId"])
+	assert.Equal(t, "disk full", entry["error"])
+}
+
+func TestRollFileOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "test.log")
+	assert.Nil(t, ioutil.WriteFile(fileName, []byte("previous\n"), 0600))
+
+	options := DefaultRollFileOptions()
+	options.Compress = false
+	options.LocalTime = true
+	options.FileMode = 0640
+	options.RotateOnStart = true
+
+	logger, err := InitRollFileLogging(fileName, false, WithRollFileOptions(options))
+	assert.Nil(t, err)
+
+	info, err := os.Stat(fileName)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	assert.Equal(t, int64(0), info.Size())
+
+	// Имя архивного файла содержит время ротации, которое задается часами lumberjack.
+	// Чтобы повторная ротация не зависела от времени, переименовываем первый архивный файл.
+	files, err := filepath.Glob(filepath.Join(dir, "test-*.log"))
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+	assert.Nil(t, os.Rename(files[0], filepath.Join(dir, "test-start.log")))
+
+	logger.Info("first")
+	rotatable, ok := logger.(RotatableLogger)
+	assert.True(t, ok)
+	assert.Nil(t, rotatable.Rotate())
+	logger.Info("second")
+	logger.Clear()
+
+	assert.True(t, strings.HasSuffix(readLogLines(t, fileName)[0], "second"))
+
+	files, err = filepath.Glob(filepath.Join(dir, "test-*.log"))
+	assert.Nil(t, err)
+	assert.Len(t, files, 2)
+
+	var dummy Logger = &DummyLogger{}
+	_, ok = dummy.(RotatableLogger)
+	assert.False(t, ok)
+}
+
+func TestLogLevels(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "test.log")
+
+	logger, err := InitRollFileLogging(fileName, false)
+	assert.Nil(t, err)
+
+	assert.True(t, logger.IsEnabled(LogLevelInfo))
+	assert.False(t, logger.IsEnabled(LogLevelDebug))
+	assert.False(t, logger.IsTraceEnabled())
+
+	store := logger.Component("store")
+	store.SetLevel(LogLevelTrace)
+	logger.SetComponentLevel("bus", LogLevelError)
+
+	logger.Debug("hidden debug")
+	store.Trace("store trace")
+	logger.Component("bus").Warning("hidden warning")
+	// Повторный вызов Component заменяет поле компонента
+	logger.Component("bus").Component("store").Trace("nested trace")
+
+	logger.SetLevel(LogLevelDebug)
+	logger.Debug("debug")
+	logger.With(HostIdField(1)).Trace("hidden trace")
+
+	logger.SetLevel(LogLevelWarning)
+	logger.Info("hidden info")
+	logger.Clear()
+
+	lines := readLogLines(t, fileName)
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], TracePrefix))
+	assert.True(t, strings.HasSuffix(lines[0], "store trace component=store"))
+	assert.True(t, strings.HasSuffix(lines[1], "nested trace component=store"))
+	assert.True(t, strings.HasPrefix(lines[2], DebugPrefix))
+
+	level, err := ParseLogLevel("debug")
+	assert.Nil(t, err)
+	assert.Equal(t, LogLevelDebug, level)
+
+	_, err = ParseLogLevel("verbose")
+	assert.NotNil(t, err)
+}
+
+func TestLogOutputs(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, 1, 1, 10, 20, 30, 0, time.Local))
+
+	var buffer bytes.Buffer
+	memory := NewMemoryLogOutput(LogLevelDebug, 2)
+
+	logger := InitLogging([]LogOutput{
+		NewWriterLogOutput(&buffer, LogOutputOptions{MinLevel: LogLevelWarning}),
+		memory}, WithClock(clock))
+
+	assert.True(t, logger.IsEnabled(LogLevelDebug))
+	assert.False(t, logger.IsEnabled(LogLevelTrace))
+
+	logger.Trace("trace")
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Warning("warning", HostIdField(1))
+
+	assert.Equal(t, []string{"INFO: 10:20:30.000: info", "WARNING: 10:20:30.000: warning hostId=1"}, memory.Lines())
+	assert.Equal(t, LogLevelWarning, memory.Entries()[1].Level)
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	assert.Len(t, lines, 1)
+	assert.True(t, strings.HasPrefix(lines[0], WarningPrefix))
+	assert.True(t, strings.HasSuffix(lines[0], "10:20:30.000: warning hostId=1"))
+}
+
+func TestRollFileLoggingWithOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "test.log")
+	memory := NewMemoryLogOutput(LogLevelTrace, 10)
+
+	logger, err := InitRollFileLogging(fileName, false, WithOutput(memory))
+	assert.Nil(t, err)
+
+	logger.Info("message")
+	logger.Clear()
+
+	assert.Len(t, readLogLines(t, fileName), 1)
+	assert.Len(t, memory.Entries(), 1)
+}
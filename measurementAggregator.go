@@ -0,0 +1,217 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type SensorKey struct {
+	DeviceId    DeviceId
+	SensorIndex uint16
+}
+
+// AggregateWindow - статистика значений датчика за интервал [StartTime, EndTime).
+// Неопределенные значения (NaN) не участвуют в статистике и учитываются в NaNCount.
+type AggregateWindow struct {
+	Key       SensorKey
+	StartTime time.Time
+	EndTime   time.Time
+	Min       float32
+	Max       float32
+	Sum       float64
+	Last      float32
+	LastTime  time.Time
+	Count     uint32
+	NaNCount  uint32
+}
+
+func (window *AggregateWindow) Mean() float32 {
+	if window.Count == 0 {
+		return float32NaN
+	}
+	return float32(window.Sum / float64(window.Count))
+}
+
+func (window *AggregateWindow) add(value float32, valueTime time.Time) {
+	if IsNaN(value) {
+		window.NaNCount++
+		return
+	}
+
+	if window.Count == 0 || value < window.Min {
+		window.Min = value
+	}
+	if window.Count == 0 || value > window.Max {
+		window.Max = value
+	}
+	window.Sum += float64(value)
+	window.Count++
+
+	if !valueTime.Before(window.LastTime) {
+		window.Last = value
+		window.LastTime = valueTime
+	}
+}
+
+// Merge добавляет в интервал статистику другого интервала того же датчика
+func (window *AggregateWindow) Merge(other *AggregateWindow) {
+	window.NaNCount += other.NaNCount
+
+	if other.Count == 0 {
+		return
+	}
+
+	if window.Count == 0 || other.Min < window.Min {
+		window.Min = other.Min
+	}
+	if window.Count == 0 || other.Max > window.Max {
+		window.Max = other.Max
+	}
+	window.Sum += other.Sum
+	window.Count += other.Count
+
+	if !other.LastTime.Before(window.LastTime) {
+		window.Last = other.Last
+		window.LastTime = other.LastTime
+	}
+}
+
+type AggregateWindowHandler func(window *AggregateWindow)
+
+// Aggregator вычисляет статистику значений датчиков по последовательным интервалам фиксированной длины.
+// Интервал закрывается при получении пакета, относящегося к следующему интервалу, или при вызове Flush.
+// Для каждого датчика запоминается конец последнего закрытого интервала, повторно интервал не открывается.
+type Aggregator struct {
+	mutex       sync.Mutex
+	windowSize  time.Duration
+	windows     map[SensorKey]*AggregateWindow
+	watermarks  map[SensorKey]time.Time
+	lateSamples uint64
+	onClosed    AggregateWindowHandler
+}
+
+func NewAggregator(windowSize time.Duration, onClosed AggregateWindowHandler) (*Aggregator, error) {
+	if windowSize <= 0 {
+		return nil, fmt.Errorf("invalid aggregation window size %v", windowSize)
+	}
+
+	return &Aggregator{
+		windowSize: windowSize,
+		windows:    make(map[SensorKey]*AggregateWindow),
+		watermarks: make(map[SensorKey]time.Time),
+		onClosed:   onClosed}, nil
+}
+
+// LateSamples возвращает количество отброшенных значений, относящихся к уже закрытым интервалам
+func (aggregator *Aggregator) LateSamples() uint64 {
+	aggregator.mutex.Lock()
+	defer aggregator.mutex.Unlock()
+	return aggregator.lateSamples
+}
+
+// AddPackage добавляет значения датчиков из пакета измерений.
+// Значения, относящиеся к уже закрытым интервалам, отбрасываются и учитываются в LateSamples.
+func (aggregator *Aggregator) AddPackage(data *DataPackage) error {
+	if data.Format != PackageFormatData {
+		return fmt.Errorf("expected data package format")
+	}
+
+	if data.IsHostPackage() {
+		return fmt.Errorf("host package %s has no measurements", data.DeviceId)
+	}
+
+	if data.IsCompressed() {
+		return fmt.Errorf("compressed data package is not supported")
+	}
+
+	converter, err := GetDataConverterFunction(data.BitsPerSensor)
+	if err != nil {
+		return err
+	}
+
+	packageTime := data.GetPackageTime()
+	windowStart := packageTime.Truncate(aggregator.windowSize)
+
+	var closed []*AggregateWindow
+
+	aggregator.mutex.Lock()
+	for sensorId := uint16(0); sensorId < data.SensorCount; sensorId++ {
+		key := SensorKey{DeviceId: data.DeviceId, SensorIndex: sensorId}
+
+		window, ok := aggregator.windows[key]
+		if ok && windowStart.Before(window.StartTime) {
+			aggregator.lateSamples++
+			continue
+		}
+
+		if watermark, found := aggregator.watermarks[key]; found && windowStart.Before(watermark) {
+			aggregator.lateSamples++
+			continue
+		}
+
+		if ok && windowStart.After(window.StartTime) {
+			closed = append(closed, aggregator.close(window))
+			ok = false
+		}
+
+		if !ok {
+			window = &AggregateWindow{
+				Key:       key,
+				StartTime: windowStart,
+				EndTime:   windowStart.Add(aggregator.windowSize)}
+			aggregator.windows[key] = window
+		}
+
+		window.add(converter(data.Data, sensorId), packageTime)
+	}
+	aggregator.mutex.Unlock()
+
+	aggregator.notify(closed)
+	return nil
+}
+
+// FlushBefore закрывает интервалы, завершившиеся не позднее указанного времени
+func (aggregator *Aggregator) FlushBefore(endTime time.Time) {
+	var closed []*AggregateWindow
+
+	aggregator.mutex.Lock()
+	for key, window := range aggregator.windows {
+		if !window.EndTime.After(endTime) {
+			closed = append(closed, aggregator.close(window))
+			delete(aggregator.windows, key)
+		}
+	}
+	aggregator.mutex.Unlock()
+
+	aggregator.notify(closed)
+}
+
+// Flush закрывает все открытые интервалы
+func (aggregator *Aggregator) Flush() {
+	var closed []*AggregateWindow
+
+	aggregator.mutex.Lock()
+	for _, window := range aggregator.windows {
+		closed = append(closed, aggregator.close(window))
+	}
+	aggregator.windows = make(map[SensorKey]*AggregateWindow)
+	aggregator.mutex.Unlock()
+
+	aggregator.notify(closed)
+}
+
+// close запоминает конец закрываемого интервала, вызывается под блокировкой
+func (aggregator *Aggregator) close(window *AggregateWindow) *AggregateWindow {
+	aggregator.watermarks[window.Key] = window.EndTime
+	return window
+}
+
+func (aggregator *Aggregator) notify(closed []*AggregateWindow) {
+	if aggregator.onClosed == nil {
+		return
+	}
+	for _, window := range closed {
+		aggregator.onClosed(window)
+	}
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// makeMeasurePackage создает пакет измерений 16 бит на датчик, значения в тысячных долях
+func makeMeasurePackage(deviceId DeviceId, packageTime time.Time, values ...uint16) *DataPackage {
+	data := make([]byte, len(values)*2)
+	for i, value := range values {
+		binary.LittleEndian.PutUint16(data[i*2:], value)
+	}
+	return &DataPackage{
+		Time:          GetUnixMicrosecondsFromTime(packageTime),
+		DeviceId:      deviceId,
+		SensorCount:   uint16(len(values)),
+		BitsPerSensor: 16,
+		Format:        PackageFormatData,
+		DataSize:      uint16(len(data)),
+		Data:          data}
+}
+
+func TestAggregator(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	var closed []*AggregateWindow
+	aggregator, err := NewAggregator(time.Minute, func(window *AggregateWindow) {
+		closed = append(closed, window)
+	})
+	assert.Nil(t, err)
+
+	assert.Nil(t, aggregator.AddPackage(makeMeasurePackage(10, start.Add(time.Second), 1000, 5)))
+	assert.Nil(t, aggregator.AddPackage(makeMeasurePackage(10, start.Add(time.Second*2), 3000, SystemUndefined16BitValue)))
+	assert.Nil(t, aggregator.AddPackage(makeMeasurePackage(10, start.Add(time.Second*3), 2000, 7)))
+	assert.Empty(t, closed)
+
+	assert.Nil(t, aggregator.AddPackage(makeMeasurePackage(10, start.Add(time.Minute), 4000)))
+	assert.Len(t, closed, 1)
+
+	window := closed[0]
+	assert.Equal(t, SensorKey{DeviceId: 10, SensorIndex: 0}, window.Key)
+	assert.True(t, start.Equal(window.StartTime))
+	assert.True(t, start.Add(time.Minute).Equal(window.EndTime))
+	assert.Equal(t, float32(1), window.Min)
+	assert.Equal(t, float32(3), window.Max)
+	assert.Equal(t, float32(2), window.Mean())
+	assert.Equal(t, float32(2), window.Last)
+	assert.Equal(t, uint32(3), window.Count)
+	assert.Equal(t, uint32(0), window.NaNCount)
+
+	// Значения для закрытого интервала отбрасываются
+	assert.Nil(t, aggregator.AddPackage(makeMeasurePackage(10, start.Add(time.Second*4), 9000)))
+
+	aggregator.Flush()
+	assert.Len(t, closed, 3)
+	for _, window := range closed[1:] {
+		if window.Key.SensorIndex == 1 {
+			assert.Equal(t, uint32(2), window.Count)
+			assert.Equal(t, uint32(1), window.NaNCount)
+		} else {
+			assert.Equal(t, float32(4), window.Last)
+			assert.Equal(t, uint32(1), window.Count)
+		}
+	}
+
+	assert.NotNil(t, aggregator.AddPackage(&DataPackage{Format: PackageFormatHeartbeat}))
+	assert.Equal(t, uint64(1), aggregator.LateSamples())
+}
+
+func TestAggregatorLateSamples(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	var closed []*AggregateWindow
+	aggregator, err := NewAggregator(time.Minute, func(window *AggregateWindow) {
+		closed = append(closed, window)
+	})
+	assert.Nil(t, err)
+
+	assert.Nil(t, aggregator.AddPackage(makeMeasurePackage(10, start.Add(time.Second), 1000)))
+	aggregator.FlushBefore(start.Add(time.Minute))
+	assert.Len(t, closed, 1)
+
+	// Опоздавшее значение не открывает повторно закрытый интервал
+	assert.Nil(t, aggregator.AddPackage(makeMeasurePackage(10, start.Add(time.Second*30), 2000)))
+	aggregator.Flush()
+	assert.Len(t, closed, 1)
+	assert.Equal(t, uint64(1), aggregator.LateSamples())
+
+	assert.Nil(t, aggregator.AddPackage(makeMeasurePackage(10, start.Add(time.Minute), 3000)))
+	aggregator.Flush()
+	assert.Len(t, closed, 2)
+	assert.True(t, start.Add(time.Minute).Equal(closed[1].StartTime))
+
+	_, err = NewAggregator(0, nil)
+	assert.NotNil(t, err)
+}
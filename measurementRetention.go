@@ -0,0 +1,645 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultAggregateSegmentDuration = time.Hour * 24
+
+const (
+	measurementCompactingExt = ".compacting"
+	compactionSourcePrefix   = "source:" // запись сегмента уровня с отметкой об объединенном источнике
+)
+
+// DownsamplingTier - уровень хранения прореженных данных.
+// Длительность сегмента предыдущего уровня должна быть кратна Resolution, чтобы каждый
+// интервал уровня полностью вычислялся из одного сегмента-источника.
+type DownsamplingTier struct {
+	Name            string        // имя каталога уровня, например "1m"
+	Resolution      time.Duration // длина интервала агрегирования
+	SegmentDuration time.Duration // длительность сегмента, DefaultAggregateSegmentDuration если 0
+	// Время хранения, 0 - бессрочно. По истечении данные прореживаются
+	// в следующий уровень (если он задан) и удаляются.
+	Retention time.Duration
+}
+
+type CompactionReport struct {
+	DownsampledSegments int   // сегменты, переписанные в прореженный вид
+	DeletedSegments     int   // удаленные сегменты
+	ReclaimedBytes      int64 // освобожденное место с учетом записанных прореженных данных
+}
+
+type CompactionHandler func(report *CompactionReport, err error)
+
+type storeCompactor struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+type aggregateRecord struct {
+	StartTime   int64
+	EndTime     int64
+	SensorIndex uint16
+	Min         float32
+	Max         float32
+	Sum         float64
+	Last        float32
+	LastTime    int64
+	Count       uint32
+	NaNCount    uint32
+}
+
+type aggregateWindowKey struct {
+	SensorIndex uint16
+	StartTime   int64
+}
+
+func prepareDownsamplingTiers(options *MeasurementStoreOptions) error {
+	names := map[string]bool{measurementRawDir: true}
+	sourceDuration := options.SegmentDuration
+	var resolution time.Duration
+
+	for i := range options.Tiers {
+		tier := &options.Tiers[i]
+
+		if tier.Name == "" || names[tier.Name] || filepath.Base(tier.Name) != tier.Name {
+			return fmt.Errorf("invalid downsampling tier name %q", tier.Name)
+		}
+		names[tier.Name] = true
+
+		if tier.Resolution <= resolution {
+			return fmt.Errorf("downsampling tier %s resolution should be greater than previous", tier.Name)
+		}
+		resolution = tier.Resolution
+
+		if sourceDuration%tier.Resolution != 0 {
+			return fmt.Errorf("source segment duration should be a multiple of tier %s resolution", tier.Name)
+		}
+
+		if tier.SegmentDuration <= 0 {
+			tier.SegmentDuration = DefaultAggregateSegmentDuration
+		}
+		if tier.SegmentDuration%time.Second != 0 || tier.SegmentDuration%tier.Resolution != 0 {
+			return fmt.Errorf("tier %s segment duration should be a multiple of resolution", tier.Name)
+		}
+		sourceDuration = tier.SegmentDuration
+	}
+
+	return nil
+}
+
+// Compact прореживает и удаляет сегменты с истекшим временем хранения относительно момента now.
+// Вызовы выполняются последовательно, прерванное сбоем прореживание завершается при следующем вызове.
+func (store *MeasurementStore) Compact(now time.Time) (*CompactionReport, error) {
+	store.compactMutex.Lock()
+	defer store.compactMutex.Unlock()
+
+	report := &CompactionReport{}
+
+	if err := store.resumeCompactions(report); err != nil {
+		return report, err
+	}
+
+	if store.options.RawRetention > 0 {
+		expireTime := now.Add(-store.options.RawRetention)
+
+		for deviceId, segments := range store.getSegmentsSnapshot(measurementRawDir) {
+			for _, segmentStart := range segments {
+				if time.Unix(segmentStart, 0).Add(store.options.SegmentDuration).After(expireTime) {
+					break
+				}
+				if err := store.compactRawSegment(deviceId, segmentStart, report); err != nil {
+					return report, err
+				}
+			}
+		}
+	}
+
+	for i, tier := range store.options.Tiers {
+		if tier.Retention <= 0 {
+			continue
+		}
+		expireTime := now.Add(-tier.Retention)
+
+		for deviceId, segments := range store.getSegmentsSnapshot(tier.Name) {
+			for _, segmentStart := range segments {
+				if time.Unix(segmentStart, 0).Add(tier.SegmentDuration).After(expireTime) {
+					break
+				}
+				if err := store.compactTierSegment(i, deviceId, segmentStart, report); err != nil {
+					return report, err
+				}
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// StartCompactor запускает периодическое выполнение Compact, результат передается в handler
+func (store *MeasurementStore) StartCompactor(interval time.Duration, handler CompactionHandler) {
+	store.compactorMutex.Lock()
+	defer store.compactorMutex.Unlock()
+
+	if store.compactor != nil {
+		return
+	}
+
+	compactor := &storeCompactor{stop: make(chan struct{}), done: make(chan struct{})}
+	store.compactor = compactor
+
+	ticker := getClock(store.options.Clock).NewTicker(interval)
+
+	go func() {
+		defer close(compactor.done)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-compactor.stop:
+				return
+			case now := <-ticker.C():
+				report, err := store.Compact(now)
+				if handler != nil {
+					handler(report, err)
+				}
+			}
+		}
+	}()
+}
+
+func (store *MeasurementStore) StopCompactor() {
+	store.compactorMutex.Lock()
+	defer store.compactorMutex.Unlock()
+
+	if store.compactor == nil {
+		return
+	}
+
+	close(store.compactor.stop)
+	<-store.compactor.done
+	store.compactor = nil
+}
+
+// ReadAggregates возвращает интервалы уровня прореживания с началом в [from, to),
+// упорядоченные по времени начала и номеру датчика
+func (store *MeasurementStore) ReadAggregates(tierName string, deviceId DeviceId, from time.Time, to time.Time) ([]*AggregateWindow, error) {
+	tier, ok := store.getTier(tierName)
+	if !ok {
+		return nil, fmt.Errorf("unknown downsampling tier %s", tierName)
+	}
+
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	fromSegment := getSegmentStart(from, tier.SegmentDuration)
+
+	var result []*AggregateWindow
+	for _, segmentStart := range store.tierSegments[tierName][deviceId] {
+		if segmentStart < fromSegment {
+			continue
+		}
+		if !time.Unix(segmentStart, 0).Before(to) {
+			break
+		}
+
+		windows, err := readAggregateSegment(store.getSegmentPath(tierName, deviceId, segmentStart), deviceId)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, window := range windows {
+			if !window.StartTime.Before(from) && window.StartTime.Before(to) {
+				result = append(result, window)
+			}
+		}
+	}
+
+	sortAggregateWindows(result)
+	return result, nil
+}
+
+func (store *MeasurementStore) compactRawSegment(deviceId DeviceId, segmentStart int64, report *CompactionReport) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if writer, ok := store.writers[deviceId]; ok && writer.startTime == segmentStart {
+		_ = writer.file.Close()
+		delete(store.writers, deviceId)
+	}
+
+	if len(store.options.Tiers) == 0 {
+		return store.removeSegment(measurementRawDir, deviceId, segmentStart, report)
+	}
+
+	return store.compactSegment(-1, deviceId, segmentStart, report)
+}
+
+func (store *MeasurementStore) compactTierSegment(tierIndex int, deviceId DeviceId, segmentStart int64, report *CompactionReport) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if tierIndex+1 == len(store.options.Tiers) {
+		return store.removeSegment(store.options.Tiers[tierIndex].Name, deviceId, segmentStart, report)
+	}
+
+	return store.compactSegment(tierIndex, deviceId, segmentStart, report)
+}
+
+// compactSegment прореживает сегмент уровня sourceTier (-1 - исходные пакеты) в следующий уровень.
+// Перед объединением сегмент переименовывается в файл *.compacting, чтобы прерванное
+// прореживание можно было повторить, не объединяя источник с уровнем дважды.
+func (store *MeasurementStore) compactSegment(sourceTier int, deviceId DeviceId, segmentStart int64, report *CompactionReport) error {
+	tierName := store.getSourceTierName(sourceTier)
+
+	pending := fmt.Sprintf("%s.%d%s", store.getSegmentPath(tierName, deviceId, segmentStart),
+		time.Now().UnixNano(), measurementCompactingExt)
+	if err := os.Rename(store.getSegmentPath(tierName, deviceId, segmentStart), pending); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := store.removeSegment(tierName, deviceId, segmentStart, report); err != nil {
+		return err
+	}
+
+	return store.applyCompaction(sourceTier, deviceId, pending, report)
+}
+
+// applyCompaction объединяет переименованный сегмент-источник со следующим уровнем и удаляет его.
+// Сегменты уровня, уже содержащие отметку об этом источнике, не изменяются.
+func (store *MeasurementStore) applyCompaction(sourceTier int, deviceId DeviceId, pending string, report *CompactionReport) error {
+	info, err := os.Stat(pending)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var windows []*AggregateWindow
+	if sourceTier < 0 {
+		windows, err = store.getRawWindows(pending)
+	} else {
+		windows, err = store.getTierWindows(sourceTier, deviceId, pending)
+	}
+	if err != nil {
+		return err
+	}
+
+	source := store.getSourceTierName(sourceTier) + "/" + filepath.Base(pending)
+	if err = store.writeTierWindows(sourceTier+1, deviceId, windows, source, report); err != nil {
+		return err
+	}
+	report.DownsampledSegments++
+
+	if err = os.Remove(pending); err != nil {
+		return err
+	}
+	report.ReclaimedBytes += info.Size()
+	return nil
+}
+
+// resumeCompactions завершает прореживание сегментов, прерванное сбоем
+func (store *MeasurementStore) resumeCompactions(report *CompactionReport) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for sourceTier := -1; sourceTier+1 < len(store.options.Tiers); sourceTier++ {
+		tierDir := store.getTierDir(store.getSourceTierName(sourceTier))
+
+		devices, err := ioutil.ReadDir(tierDir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+
+		for _, device := range devices {
+			deviceId, err := strconv.ParseInt(device.Name(), 10, 32)
+			if !device.IsDir() || err != nil {
+				continue
+			}
+
+			files, err := ioutil.ReadDir(filepath.Join(tierDir, device.Name()))
+			if err != nil {
+				return err
+			}
+
+			for _, file := range files {
+				if file.IsDir() || !strings.HasSuffix(file.Name(), measurementCompactingExt) {
+					continue
+				}
+				err = store.applyCompaction(sourceTier, DeviceId(deviceId), filepath.Join(tierDir, device.Name(), file.Name()), report)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (store *MeasurementStore) getRawWindows(path string) ([]*AggregateWindow, error) {
+	var packages []*DataPackage
+	err := readSegment(path, func(payload []byte) bool {
+		data := &DataPackage{}
+		if data.Read(bytes.NewReader(payload)) == nil && data.Format == PackageFormatData {
+			packages = append(packages, data)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(packages, func(i, j int) bool { return packages[i].Time < packages[j].Time })
+
+	var windows []*AggregateWindow
+	aggregator, err := NewAggregator(store.options.Tiers[0].Resolution, func(window *AggregateWindow) {
+		windows = append(windows, window)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, data := range packages {
+		_ = aggregator.AddPackage(data)
+	}
+	aggregator.Flush()
+
+	return windows, nil
+}
+
+func (store *MeasurementStore) getTierWindows(tierIndex int, deviceId DeviceId, path string) ([]*AggregateWindow, error) {
+	windows, err := readAggregateSegment(path, deviceId)
+	if err != nil {
+		return nil, err
+	}
+
+	resolution := store.options.Tiers[tierIndex+1].Resolution
+	merged := make(map[aggregateWindowKey]*AggregateWindow)
+	var result []*AggregateWindow
+
+	sortAggregateWindows(windows)
+	for _, window := range windows {
+		startTime := window.StartTime.Truncate(resolution)
+		key := aggregateWindowKey{SensorIndex: window.Key.SensorIndex, StartTime: startTime.UnixNano()}
+
+		target, ok := merged[key]
+		if !ok {
+			target = &AggregateWindow{Key: window.Key, StartTime: startTime, EndTime: startTime.Add(resolution)}
+			merged[key] = target
+			result = append(result, target)
+		}
+		target.Merge(window)
+	}
+
+	return result, nil
+}
+
+// writeTierWindows записывает интервалы в сегменты уровня. Интервалы с тем же началом, что и ранее
+// записанные (например, из опоздавших пакетов), объединяются с ними. Записи упорядочиваются по времени,
+// в каждый сегмент добавляется отметка об источнике source, сегменты с такой отметкой пропускаются.
+func (store *MeasurementStore) writeTierWindows(tierIndex int, deviceId DeviceId, windows []*AggregateWindow, source string, report *CompactionReport) error {
+	tier := store.options.Tiers[tierIndex]
+
+	bySegment := make(map[int64][]*AggregateWindow)
+	for _, window := range windows {
+		segmentStart := getSegmentStart(window.StartTime, tier.SegmentDuration)
+		bySegment[segmentStart] = append(bySegment[segmentStart], window)
+	}
+
+	if err := os.MkdirAll(store.getDeviceDir(tier.Name, deviceId), 0755); err != nil {
+		return err
+	}
+
+	for segmentStart, segmentWindows := range bySegment {
+		path := store.getSegmentPath(tier.Name, deviceId, segmentStart)
+
+		existing, sources, err := readAggregateSegmentSources(path, deviceId)
+		if err != nil {
+			return err
+		}
+		if sources[source] {
+			continue
+		}
+
+		var oldSize int64
+		if info, err := os.Stat(path); err == nil {
+			oldSize = info.Size()
+		}
+
+		combined := make(map[aggregateWindowKey]*AggregateWindow)
+		var result []*AggregateWindow
+		for _, window := range append(existing, segmentWindows...) {
+			key := aggregateWindowKey{SensorIndex: window.Key.SensorIndex, StartTime: window.StartTime.UnixNano()}
+			if target, ok := combined[key]; ok {
+				target.Merge(window)
+				continue
+			}
+			combined[key] = window
+			result = append(result, window)
+		}
+
+		sortAggregateWindows(result)
+
+		sources[source] = true
+		var content bytes.Buffer
+		for _, window := range result {
+			content.Write(makeSegmentRecord(encodeAggregateWindow(window)))
+		}
+		for name := range sources {
+			content.Write(makeSegmentRecord([]byte(compactionSourcePrefix + name)))
+		}
+
+		if err = writeFileAtomic(path, content.Bytes()); err != nil {
+			return err
+		}
+
+		report.ReclaimedBytes -= int64(content.Len()) - oldSize
+
+		segments := store.tierSegments[tier.Name]
+		if segments == nil {
+			segments = make(map[DeviceId][]int64)
+			store.tierSegments[tier.Name] = segments
+		}
+		segments[deviceId] = insertSegment(segments[deviceId], segmentStart)
+	}
+
+	return nil
+}
+
+func (store *MeasurementStore) removeSegment(tierName string, deviceId DeviceId, segmentStart int64, report *CompactionReport) error {
+	path := store.getSegmentPath(tierName, deviceId, segmentStart)
+
+	info, err := os.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil {
+		if err = os.Remove(path); err != nil {
+			return err
+		}
+		report.ReclaimedBytes += info.Size()
+	}
+	report.DeletedSegments++
+
+	segments := store.segments
+	if tierName != measurementRawDir {
+		segments = store.tierSegments[tierName]
+	}
+
+	deviceSegments := segments[deviceId]
+	i := sort.Search(len(deviceSegments), func(i int) bool { return deviceSegments[i] >= segmentStart })
+	if i < len(deviceSegments) && deviceSegments[i] == segmentStart {
+		deviceSegments = append(deviceSegments[:i], deviceSegments[i+1:]...)
+	}
+
+	if len(deviceSegments) == 0 {
+		delete(segments, deviceId)
+		_ = os.Remove(store.getDeviceDir(tierName, deviceId))
+	} else {
+		segments[deviceId] = deviceSegments
+	}
+
+	return nil
+}
+
+func (store *MeasurementStore) getSegmentsSnapshot(tierName string) map[DeviceId][]int64 {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	segments := store.segments
+	if tierName != measurementRawDir {
+		segments = store.tierSegments[tierName]
+	}
+
+	result := make(map[DeviceId][]int64, len(segments))
+	for deviceId, deviceSegments := range segments {
+		result[deviceId] = append([]int64(nil), deviceSegments...)
+	}
+	return result
+}
+
+func (store *MeasurementStore) getTier(tierName string) (DownsamplingTier, bool) {
+	for _, tier := range store.options.Tiers {
+		if tier.Name == tierName {
+			return tier, true
+		}
+	}
+	return DownsamplingTier{}, false
+}
+
+func readAggregateSegment(fileName string, deviceId DeviceId) ([]*AggregateWindow, error) {
+	result, _, err := readAggregateSegmentSources(fileName, deviceId)
+	return result, err
+}
+
+// readAggregateSegmentSources возвращает интервалы сегмента и отметки об объединенных в него источниках
+func readAggregateSegmentSources(fileName string, deviceId DeviceId) ([]*AggregateWindow, map[string]bool, error) {
+	var result []*AggregateWindow
+	sources := make(map[string]bool)
+
+	err := readSegment(fileName, func(payload []byte) bool {
+		if bytes.HasPrefix(payload, []byte(compactionSourcePrefix)) {
+			sources[string(payload[len(compactionSourcePrefix):])] = true
+		} else if window, err := decodeAggregateWindow(payload, deviceId); err == nil {
+			result = append(result, window)
+		}
+		return true
+	})
+
+	return result, sources, err
+}
+
+func (store *MeasurementStore) getSourceTierName(tierIndex int) string {
+	if tierIndex < 0 {
+		return measurementRawDir
+	}
+	return store.options.Tiers[tierIndex].Name
+}
+
+func encodeAggregateWindow(window *AggregateWindow) []byte {
+	record := aggregateRecord{
+		StartTime:   int64(GetUnixMicrosecondsFromTime(window.StartTime)),
+		EndTime:     int64(GetUnixMicrosecondsFromTime(window.EndTime)),
+		SensorIndex: window.Key.SensorIndex,
+		Min:         window.Min,
+		Max:         window.Max,
+		Sum:         window.Sum,
+		Last:        window.Last,
+		Count:       window.Count,
+		NaNCount:    window.NaNCount}
+
+	if !window.LastTime.IsZero() {
+		record.LastTime = int64(GetUnixMicrosecondsFromTime(window.LastTime))
+	}
+
+	var buffer bytes.Buffer
+	_ = binary.Write(&buffer, binary.LittleEndian, &record)
+	return buffer.Bytes()
+}
+
+func decodeAggregateWindow(payload []byte, deviceId DeviceId) (*AggregateWindow, error) {
+	var record aggregateRecord
+	if err := binary.Read(bytes.NewReader(payload), binary.LittleEndian, &record); err != nil {
+		return nil, err
+	}
+
+	window := &AggregateWindow{
+		Key:       SensorKey{DeviceId: deviceId, SensorIndex: record.SensorIndex},
+		StartTime: GetTimeFromUnixMicroseconds(uint64(record.StartTime)),
+		EndTime:   GetTimeFromUnixMicroseconds(uint64(record.EndTime)),
+		Min:       record.Min,
+		Max:       record.Max,
+		Sum:       record.Sum,
+		Last:      record.Last,
+		Count:     record.Count,
+		NaNCount:  record.NaNCount}
+
+	if record.LastTime != 0 {
+		window.LastTime = GetTimeFromUnixMicroseconds(uint64(record.LastTime))
+	}
+	return window, nil
+}
+
+func sortAggregateWindows(windows []*AggregateWindow) {
+	sort.Slice(windows, func(i, j int) bool {
+		if !windows[i].StartTime.Equal(windows[j].StartTime) {
+			return windows[i].StartTime.Before(windows[j].StartTime)
+		}
+		return windows[i].Key.SensorIndex < windows[j].Key.SensorIndex
+	})
+}
+
+// writeFileAtomic записывает файл через временный файл, чтобы при сбое не оставить частично записанные данные
+func writeFileAtomic(fileName string, content []byte) error {
+	tempName := fileName + ".tmp"
+
+	if err := ioutil.WriteFile(tempName, content, 0644); err != nil {
+		return err
+	}
+
+	file, err := os.Open(tempName)
+	if err == nil {
+		err = file.Sync()
+		file.Close()
+	}
+	if err != nil {
+		_ = os.Remove(tempName)
+		return err
+	}
+
+	return os.Rename(tempName, fileName)
+}
@@ -0,0 +1,187 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMeasurementStoreCompaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "measurements")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	options := MeasurementStoreOptions{
+		SegmentDuration: time.Hour,
+		RawRetention:    time.Hour * 24 * 7,
+		Tiers: []DownsamplingTier{
+			{Name: "1m", Resolution: time.Minute, Retention: time.Hour * 24 * 90},
+			{Name: "1h", Resolution: time.Hour},
+		}}
+
+	store, err := OpenMeasurementStore(dir, options)
+	assert.Nil(t, err)
+	defer store.Close()
+
+	for i := 0; i < 50; i++ {
+		assert.Nil(t, store.Append(makeMeasurePackage(10, start.Add(time.Millisecond*time.Duration(i*2)), 1000)))
+		assert.Nil(t, store.Append(makeMeasurePackage(10, start.Add(time.Millisecond*time.Duration(i*2+1)), 3000)))
+	}
+	assert.Nil(t, store.Append(makeMeasurePackage(10, start.Add(time.Minute*2), 5000)))
+
+	// Время хранения сырых данных не истекло
+	report, err := store.Compact(start.Add(time.Hour * 24))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, report.DeletedSegments)
+
+	report, err = store.Compact(start.Add(time.Hour * 24 * 8))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.DownsampledSegments)
+	assert.Equal(t, 1, report.DeletedSegments)
+	assert.True(t, report.ReclaimedBytes > 0)
+
+	packages, err := store.ReadPackages(10, start, start.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Empty(t, packages)
+
+	windows, err := store.ReadAggregates("1m", 10, start, start.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Len(t, windows, 2)
+	assert.Equal(t, uint32(100), windows[0].Count)
+	assert.Equal(t, float32(2), windows[0].Mean())
+	assert.Equal(t, float32(5), windows[1].Last)
+
+	report, err = store.Compact(start.Add(time.Hour * 24 * 100))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.DownsampledSegments)
+
+	windows, err = store.ReadAggregates("1m", 10, start, start.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Empty(t, windows)
+
+	windows, err = store.ReadAggregates("1h", 10, start, start.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Len(t, windows, 1)
+	assert.Equal(t, uint32(101), windows[0].Count)
+	assert.Equal(t, float32(1), windows[0].Min)
+	assert.Equal(t, float32(5), windows[0].Max)
+	assert.Equal(t, float32(5), windows[0].Last)
+
+	// Последний уровень хранится бессрочно
+	report, err = store.Compact(start.Add(time.Hour * 24 * 1000))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, report.DeletedSegments)
+
+	_, err = store.ReadAggregates("5m", 10, start, start.Add(time.Hour))
+	assert.NotNil(t, err)
+}
+
+func TestMeasurementStoreLateCompaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "measurements")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	options := MeasurementStoreOptions{
+		SegmentDuration: time.Hour,
+		RawRetention:    time.Hour,
+		Tiers:           []DownsamplingTier{{Name: "1m", Resolution: time.Minute}}}
+
+	store, err := OpenMeasurementStore(dir, options)
+	assert.Nil(t, err)
+	defer store.Close()
+
+	assert.Nil(t, store.Append(makeMeasurePackage(10, start.Add(time.Second), 1000, 2000)))
+	_, err = store.Compact(start.Add(time.Hour * 3))
+	assert.Nil(t, err)
+
+	// Опоздавшие пакеты для уже прореженного интервала дополняют его статистику
+	assert.Nil(t, store.Append(makeMeasurePackage(10, start.Add(time.Second*2), 3000, 4000)))
+	_, err = store.Compact(start.Add(time.Hour * 3))
+	assert.Nil(t, err)
+
+	windows, err := store.ReadAggregates("1m", 10, start, start.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Len(t, windows, 2)
+	assert.Equal(t, uint16(0), windows[0].Key.SensorIndex)
+	assert.Equal(t, uint32(2), windows[0].Count)
+	assert.Equal(t, float32(1), windows[0].Min)
+	assert.Equal(t, float32(3), windows[0].Max)
+	assert.Equal(t, uint16(1), windows[1].Key.SensorIndex)
+	assert.Equal(t, float32(4), windows[1].Last)
+}
+
+func TestMeasurementStoreResumeCompaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "measurements")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	options := MeasurementStoreOptions{
+		SegmentDuration: time.Hour,
+		RawRetention:    time.Hour,
+		Tiers:           []DownsamplingTier{{Name: "1m", Resolution: time.Minute}}}
+
+	store, err := OpenMeasurementStore(dir, options)
+	assert.Nil(t, err)
+	defer store.Close()
+
+	assert.Nil(t, store.Append(makeMeasurePackage(10, start.Add(time.Second), 1000)))
+	assert.Nil(t, store.Close())
+
+	// Сбой после переименования сегмента-источника
+	path := store.getSegmentPath(measurementRawDir, 10, start.Unix())
+	pending := path + ".1" + measurementCompactingExt
+	content, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Nil(t, os.Rename(path, pending))
+
+	store, err = OpenMeasurementStore(dir, options)
+	assert.Nil(t, err)
+	defer store.Close()
+
+	report, err := store.Compact(start.Add(time.Hour * 3))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.DownsampledSegments)
+
+	// Сбой после объединения с уровнем, но до удаления источника
+	assert.Nil(t, ioutil.WriteFile(pending, content, 0644))
+	report, err = store.Compact(start.Add(time.Hour * 3))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.DownsampledSegments)
+
+	_, err = os.Stat(pending)
+	assert.True(t, os.IsNotExist(err))
+
+	windows, err := store.ReadAggregates("1m", 10, start, start.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Len(t, windows, 1)
+	assert.Equal(t, uint32(1), windows[0].Count)
+	assert.Equal(t, float64(1), windows[0].Sum)
+}
+
+func TestInvalidDownsamplingTiers(t *testing.T) {
+	tests := []struct {
+		name  string
+		tiers []DownsamplingTier
+	}{
+		{"RawName", []DownsamplingTier{{Name: "raw", Resolution: time.Minute}}},
+		{"DecreasingResolution", []DownsamplingTier{
+			{Name: "1h", Resolution: time.Hour},
+			{Name: "1m", Resolution: time.Minute}}},
+		{"NotMultiple", []DownsamplingTier{{Name: "7m", Resolution: time.Minute * 7}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := MeasurementStoreOptions{SegmentDuration: time.Hour, Tiers: test.tiers}
+			assert.NotNil(t, prepareDownsamplingTiers(&options))
+		})
+	}
+}
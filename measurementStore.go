@@ -0,0 +1,483 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	measurementSegmentExt      = ".seg"
+	measurementRawDir          = "raw"
+	measurementRecordHeader    = 8
+	measurementMaxRecordLength = 1 << 20
+)
+
+const DefaultSegmentDuration = time.Hour
+
+type MeasurementStoreOptions struct {
+	SegmentDuration time.Duration // длительность сегмента, DefaultSegmentDuration если 0
+	// Время хранения пакетов измерений, 0 - бессрочно. По истечении пакеты
+	// прореживаются в первый уровень Tiers (если он задан) и удаляются.
+	RawRetention time.Duration
+	Tiers        []DownsamplingTier // уровни прореживания в порядке увеличения интервала
+	Clock        Clock              // часы для периодического выполнения Compact
+}
+
+// SeriesPoint - значение датчика в момент времени пакета
+type SeriesPoint struct {
+	Time        time.Time
+	Value       float32
+	IsUndefined bool
+}
+
+type segmentFile struct {
+	startTime int64 // начало сегмента, секунды unix
+	file      *os.File
+}
+
+// MeasurementStore - файловое хранилище пакетов измерений.
+// Пакеты дописываются в файлы сегментов raw/<DeviceId>/<начало сегмента>.seg,
+// каждая запись содержит длину, контрольную сумму и сериализованный пакет.
+type MeasurementStore struct {
+	mutex        sync.RWMutex
+	dir          string
+	options      MeasurementStoreOptions
+	segments     map[DeviceId][]int64
+	tierSegments map[string]map[DeviceId][]int64
+	writers      map[DeviceId]*segmentFile
+	tornSegments map[string]bool // сегменты, которые не удалось обрезать после ошибки записи
+
+	compactMutex   sync.Mutex
+	compactorMutex sync.Mutex
+	compactor      *storeCompactor
+}
+
+// OpenMeasurementStore открывает хранилище в каталоге dir, создавая его при необходимости.
+// Недописанные записи обрезаются во всех сегментах: при записи пакетов не по порядку
+// времени дописываться могут и ранее закрытые сегменты.
+func OpenMeasurementStore(dir string, options MeasurementStoreOptions) (*MeasurementStore, error) {
+	if options.SegmentDuration <= 0 {
+		options.SegmentDuration = DefaultSegmentDuration
+	}
+	if options.SegmentDuration%time.Second != 0 {
+		return nil, fmt.Errorf("segment duration should be a whole number of seconds")
+	}
+	if err := prepareDownsamplingTiers(&options); err != nil {
+		return nil, err
+	}
+
+	store := &MeasurementStore{
+		dir:          dir,
+		options:      options,
+		segments:     make(map[DeviceId][]int64),
+		tierSegments: make(map[string]map[DeviceId][]int64),
+		writers:      make(map[DeviceId]*segmentFile),
+		tornSegments: make(map[string]bool)}
+
+	if err := os.MkdirAll(store.getTierDir(measurementRawDir), 0755); err != nil {
+		return nil, err
+	}
+
+	segments, err := store.loadSegments(measurementRawDir)
+	if err != nil {
+		return nil, err
+	}
+	store.segments = segments
+
+	for deviceId, deviceSegments := range store.segments {
+		for _, segmentStart := range deviceSegments {
+			if err = truncateTornRecords(store.getSegmentPath(measurementRawDir, deviceId, segmentStart)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, tier := range options.Tiers {
+		if store.tierSegments[tier.Name], err = store.loadSegments(tier.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+func (store *MeasurementStore) Append(data *DataPackage) error {
+	if data.IsHostPackage() {
+		return fmt.Errorf("host package %s is not stored with device measurements", data.DeviceId)
+	}
+
+	payload := data.Bytes()
+	if len(payload) > measurementMaxRecordLength {
+		return fmt.Errorf("package size %d exceeds maximum record length", len(payload))
+	}
+
+	record := makeSegmentRecord(payload)
+	segmentStart := getSegmentStart(data.GetPackageTime(), store.options.SegmentDuration)
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	writer, err := store.getWriter(data.DeviceId, segmentStart)
+	if err != nil {
+		return err
+	}
+
+	info, err := writer.file.Stat()
+	if err == nil {
+		if _, err = writer.file.Write(record); err == nil {
+			return nil
+		}
+		// Недописанная запись в середине сегмента скрыла бы все последующие записи
+		if writer.file.Truncate(info.Size()) == nil {
+			return err
+		}
+	}
+
+	// Сегмент закрывается и при следующей записи открывается заново с обрезкой хвоста
+	_ = writer.file.Close()
+	delete(store.writers, data.DeviceId)
+	store.tornSegments[writer.file.Name()] = true
+	return err
+}
+
+// Scan передает в handler пакеты устройства с временем в интервале [from, to) в порядке сегментов.
+// Внутри сегмента пакеты передаются в порядке записи. Обход прекращается, если handler вернул false.
+// Пакеты сегмента считываются под блокировкой, handler вызывается без нее и может обращаться к хранилищу.
+func (store *MeasurementStore) Scan(deviceId DeviceId, from time.Time, to time.Time, handler func(data *DataPackage) bool) error {
+	fromSegment := getSegmentStart(from, store.options.SegmentDuration)
+
+	store.mutex.RLock()
+	segments := append([]int64(nil), store.segments[deviceId]...)
+	store.mutex.RUnlock()
+
+	for _, segmentStart := range segments {
+		if segmentStart < fromSegment {
+			continue
+		}
+		if !time.Unix(segmentStart, 0).Before(to) {
+			break
+		}
+
+		packages, err := store.readSegmentPackages(deviceId, segmentStart, from, to)
+		if err != nil {
+			return err
+		}
+
+		for _, data := range packages {
+			if !handler(data) {
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+func (store *MeasurementStore) readSegmentPackages(deviceId DeviceId, segmentStart int64, from time.Time, to time.Time) ([]*DataPackage, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	var result []*DataPackage
+	err := readSegment(store.getSegmentPath(measurementRawDir, deviceId, segmentStart), func(payload []byte) bool {
+		data := &DataPackage{}
+		if data.Read(bytes.NewReader(payload)) != nil {
+			return true
+		}
+
+		packageTime := data.GetPackageTime()
+		if !packageTime.Before(from) && packageTime.Before(to) {
+			result = append(result, data)
+		}
+		return true
+	})
+	return result, err
+}
+
+// ReadPackages возвращает пакеты устройства с временем в интервале [from, to), упорядоченные по времени
+func (store *MeasurementStore) ReadPackages(deviceId DeviceId, from time.Time, to time.Time) ([]*DataPackage, error) {
+	var result []*DataPackage
+
+	err := store.Scan(deviceId, from, to, func(data *DataPackage) bool {
+		result = append(result, data)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Time < result[j].Time })
+	return result, nil
+}
+
+// ReadSeries возвращает значения датчика из пакетов измерений в интервале [from, to), упорядоченные по времени
+func (store *MeasurementStore) ReadSeries(deviceId DeviceId, sensorIndex uint16, from time.Time, to time.Time) ([]SeriesPoint, error) {
+	packages, err := store.ReadPackages(deviceId, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []SeriesPoint
+	for _, data := range packages {
+		if data.Format != PackageFormatData || data.IsCompressed() || sensorIndex >= data.SensorCount {
+			continue
+		}
+
+		converter, err := GetDataConverterFunction(data.BitsPerSensor)
+		if err != nil {
+			continue
+		}
+
+		value := converter(data.Data, sensorIndex)
+		result = append(result, SeriesPoint{Time: data.GetPackageTime(), Value: value, IsUndefined: IsNaN(value)})
+	}
+
+	return result, nil
+}
+
+// GetDevices возвращает устройства, для которых в хранилище есть данные
+func (store *MeasurementStore) GetDevices() []DeviceId {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	result := make([]DeviceId, 0, len(store.segments))
+	for deviceId := range store.segments {
+		result = append(result, deviceId)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// Sync сбрасывает записанные данные открытых сегментов на диск
+func (store *MeasurementStore) Sync() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for _, writer := range store.writers {
+		if err := writer.file.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (store *MeasurementStore) Close() error {
+	store.StopCompactor()
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var result error
+	for deviceId, writer := range store.writers {
+		if err := writer.file.Close(); err != nil && result == nil {
+			result = err
+		}
+		delete(store.writers, deviceId)
+	}
+	return result
+}
+
+func (store *MeasurementStore) getWriter(deviceId DeviceId, segmentStart int64) (*segmentFile, error) {
+	writer, ok := store.writers[deviceId]
+	if ok && writer.startTime == segmentStart {
+		return writer, nil
+	}
+
+	if ok {
+		_ = writer.file.Close()
+		delete(store.writers, deviceId)
+	}
+
+	if err := os.MkdirAll(store.getDeviceDir(measurementRawDir, deviceId), 0755); err != nil {
+		return nil, err
+	}
+
+	path := store.getSegmentPath(measurementRawDir, deviceId, segmentStart)
+	if store.tornSegments[path] {
+		if err := truncateTornRecords(path); err != nil {
+			return nil, err
+		}
+		delete(store.tornSegments, path)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	writer = &segmentFile{startTime: segmentStart, file: file}
+	store.writers[deviceId] = writer
+	store.segments[deviceId] = insertSegment(store.segments[deviceId], segmentStart)
+	return writer, nil
+}
+
+func getSegmentStart(value time.Time, duration time.Duration) int64 {
+	seconds := int64(duration / time.Second)
+	start := value.Unix()
+	start -= start % seconds
+	if start > value.Unix() {
+		start -= seconds
+	}
+	return start
+}
+
+func (store *MeasurementStore) getTierDir(tier string) string {
+	return filepath.Join(store.dir, tier)
+}
+
+func (store *MeasurementStore) getDeviceDir(tier string, deviceId DeviceId) string {
+	return filepath.Join(store.dir, tier, strconv.FormatInt(int64(deviceId), 10))
+}
+
+func (store *MeasurementStore) getSegmentPath(tier string, deviceId DeviceId, segmentStart int64) string {
+	return filepath.Join(store.getDeviceDir(tier, deviceId), strconv.FormatInt(segmentStart, 10)+measurementSegmentExt)
+}
+
+// loadSegments возвращает отсортированные по времени сегменты устройств уровня хранения
+func (store *MeasurementStore) loadSegments(tier string) (map[DeviceId][]int64, error) {
+	result := make(map[DeviceId][]int64)
+
+	devices, err := ioutil.ReadDir(store.getTierDir(tier))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return nil, err
+	}
+
+	for _, device := range devices {
+		if !device.IsDir() {
+			continue
+		}
+		deviceId, err := strconv.ParseInt(device.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+
+		files, err := ioutil.ReadDir(filepath.Join(store.getTierDir(tier), device.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var segments []int64
+		for _, file := range files {
+			if file.IsDir() || !strings.HasSuffix(file.Name(), measurementSegmentExt) {
+				continue
+			}
+			segmentStart, err := strconv.ParseInt(strings.TrimSuffix(file.Name(), measurementSegmentExt), 10, 64)
+			if err != nil {
+				continue
+			}
+			segments = append(segments, segmentStart)
+		}
+
+		if len(segments) > 0 {
+			sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+			result[DeviceId(deviceId)] = segments
+		}
+	}
+
+	return result, nil
+}
+
+func insertSegment(segments []int64, segmentStart int64) []int64 {
+	i := sort.Search(len(segments), func(i int) bool { return segments[i] >= segmentStart })
+	if i < len(segments) && segments[i] == segmentStart {
+		return segments
+	}
+	segments = append(segments, 0)
+	copy(segments[i+1:], segments[i:])
+	segments[i] = segmentStart
+	return segments
+}
+
+func makeSegmentRecord(payload []byte) []byte {
+	record := make([]byte, measurementRecordHeader+len(payload))
+	binary.LittleEndian.PutUint32(record, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+	copy(record[measurementRecordHeader:], payload)
+	return record
+}
+
+var errTornRecord = errors.New("torn record in segment")
+
+// readSegment передает в handler содержимое записей сегмента.
+// Чтение прекращается на первой поврежденной записи.
+func readSegment(fileName string, handler func(payload []byte) bool) error {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for pos := 0; pos < len(content); {
+		payload, err := getSegmentRecord(content[pos:])
+		if err != nil {
+			return nil
+		}
+		if !handler(payload) {
+			return nil
+		}
+		pos += measurementRecordHeader + len(payload)
+	}
+	return nil
+}
+
+func getSegmentRecord(data []byte) ([]byte, error) {
+	if len(data) < measurementRecordHeader {
+		return nil, errTornRecord
+	}
+
+	length := int(binary.LittleEndian.Uint32(data))
+	checksum := binary.LittleEndian.Uint32(data[4:])
+
+	if length > measurementMaxRecordLength || len(data) < measurementRecordHeader+length {
+		return nil, errTornRecord
+	}
+
+	payload := data[measurementRecordHeader : measurementRecordHeader+length]
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, errTornRecord
+	}
+	return payload, nil
+}
+
+// truncateTornRecords обрезает файл сегмента по последней целой записи
+func truncateTornRecords(fileName string) error {
+	file, err := os.OpenFile(fileName, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	validSize := 0
+	for validSize < len(content) {
+		payload, err := getSegmentRecord(content[validSize:])
+		if err != nil {
+			break
+		}
+		validSize += measurementRecordHeader + len(payload)
+	}
+
+	if validSize == len(content) {
+		return nil
+	}
+	return file.Truncate(int64(validSize))
+}
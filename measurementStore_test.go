@@ -0,0 +1,139 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMeasurementStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "measurements")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	store, err := OpenMeasurementStore(dir, MeasurementStoreOptions{SegmentDuration: time.Hour})
+	assert.Nil(t, err)
+
+	assert.Nil(t, store.Append(makeMeasurePackage(10, start.Add(time.Minute*90), 3000)))
+	assert.Nil(t, store.Append(makeMeasurePackage(10, start.Add(time.Minute), 1000)))
+	assert.Nil(t, store.Append(makeMeasurePackage(10, start.Add(time.Minute*2), SystemUndefined16BitValue)))
+	assert.Nil(t, store.Append(makeMeasurePackage(20, start.Add(time.Minute), 2000)))
+	assert.Nil(t, store.Close())
+
+	assert.Equal(t, []DeviceId{10, 20}, store.GetDevices())
+
+	packages, err := store.ReadPackages(10, start, start.Add(time.Hour*2))
+	assert.Nil(t, err)
+	assert.Len(t, packages, 3)
+	assert.Equal(t, GetUnixMicrosecondsFromTime(start.Add(time.Minute)), packages[0].Time)
+
+	series, err := store.ReadSeries(10, 0, start.Add(time.Minute*2), start.Add(time.Hour*2))
+	assert.Nil(t, err)
+	assert.Len(t, series, 2)
+	assert.True(t, series[0].IsUndefined)
+	assert.Equal(t, float32(3), series[1].Value)
+
+	// Имитируем недописанную запись в конце последнего сегмента
+	segmentPath := filepath.Join(dir, "raw", "10", "1577876400.seg")
+	info, err := os.Stat(segmentPath)
+	assert.Nil(t, err)
+
+	file, err := os.OpenFile(segmentPath, os.O_WRONLY|os.O_APPEND, 0)
+	assert.Nil(t, err)
+	_, err = file.Write([]byte{100, 0, 0, 0, 1, 2})
+	assert.Nil(t, err)
+	file.Close()
+
+	store, err = OpenMeasurementStore(dir, MeasurementStoreOptions{SegmentDuration: time.Hour})
+	assert.Nil(t, err)
+	defer store.Close()
+
+	truncated, err := os.Stat(segmentPath)
+	assert.Nil(t, err)
+	assert.Equal(t, info.Size(), truncated.Size())
+
+	assert.Nil(t, store.Append(makeMeasurePackage(10, start.Add(time.Minute*91), 4000)))
+
+	series, err = store.ReadSeries(10, 0, start.Add(time.Hour), start.Add(time.Hour*2))
+	assert.Nil(t, err)
+	assert.Len(t, series, 2)
+	assert.Equal(t, float32(4), series[1].Value)
+}
+
+func TestMeasurementStoreTornMiddleSegment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "measurements")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	store, err := OpenMeasurementStore(dir, MeasurementStoreOptions{SegmentDuration: time.Hour})
+	assert.Nil(t, err)
+
+	// Пакеты не по порядку времени: первый сегмент открывается повторно
+	assert.Nil(t, store.Append(makeMeasurePackage(10, start.Add(time.Minute), 1000)))
+	assert.Nil(t, store.Append(makeMeasurePackage(10, start.Add(time.Minute*90), 3000)))
+	assert.Nil(t, store.Append(makeMeasurePackage(10, start.Add(time.Minute*2), 2000)))
+	assert.Nil(t, store.Close())
+
+	// Имитируем недописанную запись в конце первого (не последнего) сегмента
+	segmentPath := filepath.Join(dir, "raw", "10", "1577872800.seg")
+	file, err := os.OpenFile(segmentPath, os.O_WRONLY|os.O_APPEND, 0)
+	assert.Nil(t, err)
+	_, err = file.Write([]byte{100, 0, 0, 0, 1, 2})
+	assert.Nil(t, err)
+	file.Close()
+
+	store, err = OpenMeasurementStore(dir, MeasurementStoreOptions{SegmentDuration: time.Hour})
+	assert.Nil(t, err)
+	defer store.Close()
+
+	assert.Nil(t, store.Append(makeMeasurePackage(10, start.Add(time.Minute*3), 4000)))
+
+	series, err := store.ReadSeries(10, 0, start, start.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Len(t, series, 3)
+	assert.Equal(t, float32(4), series[2].Value)
+
+	// Обработчик может дописывать пакеты в хранилище
+	err = store.Scan(10, start, start.Add(time.Hour*2), func(data *DataPackage) bool {
+		assert.Nil(t, store.Append(makeMeasurePackage(20, data.GetPackageTime(), 5000)))
+		return true
+	})
+	assert.Nil(t, err)
+
+	packages, err := store.ReadPackages(20, start, start.Add(time.Hour*2))
+	assert.Nil(t, err)
+	assert.Len(t, packages, 4)
+}
+
+func TestMeasurementStoreFailedWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "measurements")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	store, err := OpenMeasurementStore(dir, MeasurementStoreOptions{SegmentDuration: time.Hour})
+	assert.Nil(t, err)
+	defer store.Close()
+
+	assert.Nil(t, store.Append(makeMeasurePackage(10, start, 1000)))
+
+	// Имитируем ошибку записи: файл сегмента закрыт
+	assert.Nil(t, store.writers[10].file.Close())
+	assert.NotNil(t, store.Append(makeMeasurePackage(10, start.Add(time.Second), 2000)))
+
+	// Сегмент открывается заново, последующие записи не теряются
+	assert.Nil(t, store.Append(makeMeasurePackage(10, start.Add(time.Second*2), 3000)))
+
+	series, err := store.ReadSeries(10, 0, start, start.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Len(t, series, 2)
+	assert.Equal(t, float32(3), series[1].Value)
+}
@@ -0,0 +1,193 @@
+package core
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ObjectNwaState описывает текущее состояние объекта относительно АНР
+type ObjectNwaState struct {
+	ObjectId     uint32
+	NwaStateId   int32     // текущее САНР объекта
+	StateTime    time.Time // время перехода в текущее САНР
+	IsOutside    bool      // true если объект вышел из АНР
+	LeaveStateId int32     // САНР из которого вышел объект, если IsOutside = true
+	LeaveTime    time.Time // время выхода из АНР, если IsOutside = true
+	AlgorithmId  uint32    // алгоритм, зафиксировавший выход из АНР, если IsOutside = true
+}
+
+// Since возвращает время, с которого действует текущее состояние объекта
+func (state *ObjectNwaState) Since() time.Time {
+	if state.IsOutside {
+		return state.LeaveTime
+	}
+	return state.StateTime
+}
+
+// Equal сравнивает состояния, время сравнивается методом time.Time.Equal
+func (state *ObjectNwaState) Equal(other *ObjectNwaState) bool {
+	return state.ObjectId == other.ObjectId &&
+		state.NwaStateId == other.NwaStateId &&
+		state.StateTime.Equal(other.StateTime) &&
+		state.IsOutside == other.IsOutside &&
+		state.LeaveStateId == other.LeaveStateId &&
+		state.LeaveTime.Equal(other.LeaveTime) &&
+		state.AlgorithmId == other.AlgorithmId
+}
+
+// NwaChangeHandler вызывается при изменении состояния объекта, previous = nil для нового объекта
+type NwaChangeHandler func(hostId int32, previous *ObjectNwaState, current *ObjectNwaState)
+
+// NwaTracker хранит для каждого объекта текущее САНР и признак выхода из АНР
+type NwaTracker struct {
+	mutex    sync.RWMutex
+	hosts    map[int32]map[uint32]*ObjectNwaState
+	onChange NwaChangeHandler
+}
+
+func NewNwaTracker(onChange NwaChangeHandler) *NwaTracker {
+	return &NwaTracker{hosts: make(map[int32]map[uint32]*ObjectNwaState), onChange: onChange}
+}
+
+type nwaChange struct {
+	previous *ObjectNwaState
+	current  *ObjectNwaState
+}
+
+// Apply обрабатывает сетевой пакет. Пакеты без событий игнорируются.
+func (tracker *NwaTracker) Apply(networkPackage *NetworkPackage) error {
+	if networkPackage.Data.Format != PackageFormatEvents {
+		return nil
+	}
+
+	events, err := networkPackage.Data.ParseEventsPackage()
+	if err != nil {
+		return err
+	}
+
+	tracker.ApplyEvents(networkPackage.HostId, events)
+	return nil
+}
+
+func (tracker *NwaTracker) ApplyEvents(hostId int32, events *PackageEvents) {
+	var changes []nwaChange
+
+	tracker.mutex.Lock()
+
+	host, ok := tracker.hosts[hostId]
+	if !ok {
+		host = make(map[uint32]*ObjectNwaState)
+		tracker.hosts[hostId] = host
+	}
+
+	for _, objectId := range getNwaEventObjects(events) {
+		previous := host[objectId]
+
+		current := &ObjectNwaState{ObjectId: objectId, NwaStateId: -1, LeaveStateId: -1}
+		if previous != nil {
+			*current = *previous
+		}
+
+		stateEvent := events.ObjectNwaStateLeaveEnter[objectId]
+		leaveEvent := events.ObjectNwaChangeState[objectId]
+
+		// Если в пакете есть оба события, применяем их в порядке времени
+		if stateEvent != nil && leaveEvent != nil && leaveEvent.EventTime.Before(stateEvent.EventTime) {
+			applyNwaLeaveEvent(current, leaveEvent)
+			applyNwaStateEvent(current, stateEvent)
+		} else {
+			if stateEvent != nil {
+				applyNwaStateEvent(current, stateEvent)
+			}
+			if leaveEvent != nil {
+				applyNwaLeaveEvent(current, leaveEvent)
+			}
+		}
+
+		if previous != nil && previous.Equal(current) {
+			continue
+		}
+
+		host[objectId] = current
+		changes = append(changes, nwaChange{previous: previous, current: current})
+	}
+
+	tracker.mutex.Unlock()
+
+	if tracker.onChange == nil {
+		return
+	}
+	for _, change := range changes {
+		currentCopy := *change.current
+		tracker.onChange(hostId, change.previous, &currentCopy)
+	}
+}
+
+func (tracker *NwaTracker) GetObjectState(hostId int32, objectId uint32) (ObjectNwaState, bool) {
+	tracker.mutex.RLock()
+	defer tracker.mutex.RUnlock()
+
+	state, ok := tracker.hosts[hostId][objectId]
+	if !ok {
+		return ObjectNwaState{}, false
+	}
+	return *state, true
+}
+
+// GetObjectsOutsideNwa возвращает объекты хоста, находящиеся вне АНР, упорядоченные по времени выхода
+func (tracker *NwaTracker) GetObjectsOutsideNwa(hostId int32) []ObjectNwaState {
+	tracker.mutex.RLock()
+	defer tracker.mutex.RUnlock()
+
+	var result []ObjectNwaState
+	for _, state := range tracker.hosts[hostId] {
+		if state.IsOutside {
+			result = append(result, *state)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LeaveTime.Before(result[j].LeaveTime)
+	})
+	return result
+}
+
+func applyNwaStateEvent(state *ObjectNwaState, event *ObjectNwaStateChangeEventInfo) {
+	state.NwaStateId = event.NwaStateId
+	state.StateTime = event.EventTime
+}
+
+func applyNwaLeaveEvent(state *ObjectNwaState, event *ObjectNwaStateLeaveEventInfo) {
+	if event.IsStarted {
+		// Объект вышел из САНР
+		state.IsOutside = true
+		state.LeaveStateId = event.StateId
+		state.LeaveTime = event.EventTime
+		state.AlgorithmId = event.AlgorithmId
+		return
+	}
+
+	// Объект вернулся в САНР
+	state.IsOutside = false
+	state.LeaveStateId = -1
+	state.LeaveTime = time.Time{}
+	state.AlgorithmId = 0
+	state.NwaStateId = event.StateId
+	state.StateTime = event.EventTime
+}
+
+func getNwaEventObjects(events *PackageEvents) []uint32 {
+	var result []uint32
+	for objectId := range events.ObjectNwaStateLeaveEnter {
+		result = append(result, objectId)
+	}
+	for objectId := range events.ObjectNwaChangeState {
+		if _, ok := events.ObjectNwaStateLeaveEnter[objectId]; !ok {
+			result = append(result, objectId)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNwaTracker(t *testing.T) {
+	now := time.Now()
+
+	var changes []*ObjectNwaState
+	tracker := NewNwaTracker(func(hostId int32, previous *ObjectNwaState, current *ObjectNwaState) {
+		assert.Equal(t, int32(1), hostId)
+		changes = append(changes, current)
+	})
+
+	tracker.ApplyEvents(1, &PackageEvents{
+		ObjectNwaStateLeaveEnter: map[uint32]*ObjectNwaStateChangeEventInfo{
+			100: {ObjectId: 100, NwaStateId: 23, EventTime: now.Add(-time.Hour)},
+		}})
+
+	state, ok := tracker.GetObjectState(1, 100)
+	assert.True(t, ok)
+	assert.Equal(t, int32(23), state.NwaStateId)
+	assert.False(t, state.IsOutside)
+	assert.Len(t, changes, 1)
+
+	tracker.ApplyEvents(1, &PackageEvents{
+		ObjectNwaChangeState: map[uint32]*ObjectNwaStateLeaveEventInfo{
+			100: {ObjectId: 100, AlgorithmId: 4, StateId: 23, IsStarted: true, EventTime: now.Add(-time.Minute)},
+		}})
+
+	outside := tracker.GetObjectsOutsideNwa(1)
+	assert.Len(t, outside, 1)
+	assert.Equal(t, uint32(4), outside[0].AlgorithmId)
+	assert.Equal(t, int32(23), outside[0].LeaveStateId)
+	assert.Equal(t, now.Add(-time.Minute), outside[0].Since())
+	assert.Len(t, changes, 2)
+
+	// Повторное событие не изменяет состояние
+	tracker.ApplyEvents(1, &PackageEvents{
+		ObjectNwaChangeState: map[uint32]*ObjectNwaStateLeaveEventInfo{
+			100: {ObjectId: 100, AlgorithmId: 4, StateId: 23, IsStarted: true, EventTime: now.Add(-time.Minute)},
+		}})
+	assert.Len(t, changes, 2)
+
+	tracker.ApplyEvents(1, &PackageEvents{
+		ObjectNwaChangeState: map[uint32]*ObjectNwaStateLeaveEventInfo{
+			100: {ObjectId: 100, AlgorithmId: 4, StateId: 24, IsStarted: false, EventTime: now},
+		}})
+
+	state, _ = tracker.GetObjectState(1, 100)
+	assert.False(t, state.IsOutside)
+	assert.Equal(t, int32(24), state.NwaStateId)
+	assert.Empty(t, tracker.GetObjectsOutsideNwa(1))
+	assert.Len(t, changes, 3)
+}
+
+func TestNwaTrackerSameStateInOtherLocation(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	changes := 0
+	tracker := NewNwaTracker(func(hostId int32, previous *ObjectNwaState, current *ObjectNwaState) {
+		changes++
+	})
+
+	stateEvent := func(eventTime time.Time) *PackageEvents {
+		return &PackageEvents{ObjectNwaStateLeaveEnter: map[uint32]*ObjectNwaStateChangeEventInfo{
+			100: {ObjectId: 100, NwaStateId: 2, EventTime: eventTime}}}
+	}
+
+	tracker.ApplyEvents(1, stateEvent(start))
+	// То же время в другом часовом поясе не является изменением состояния
+	tracker.ApplyEvents(1, stateEvent(start.In(time.FixedZone("MSK", 3*60*60))))
+	assert.Equal(t, 1, changes)
+}
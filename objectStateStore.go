@@ -0,0 +1,117 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type hostObjectStates struct {
+	states     map[uint32]uint16
+	updateTime time.Time
+}
+
+// ObjectStateStore хранит текущие состояния объектов для каждого хоста.
+// Полный срез состояний заменяет данные хоста, пакеты изменений дополняют их.
+type ObjectStateStore struct {
+	mutex sync.RWMutex
+	hosts map[int32]*hostObjectStates
+}
+
+func NewObjectStateStore() *ObjectStateStore {
+	return &ObjectStateStore{hosts: make(map[int32]*hostObjectStates)}
+}
+
+// Apply обновляет состояния по сетевому пакету. Пакеты других форматов игнорируются.
+func (store *ObjectStateStore) Apply(networkPackage *NetworkPackage) error {
+	switch networkPackage.Data.Format {
+	case PackageFormatFullObjectStates:
+		return store.ApplyFullState(networkPackage.HostId, &networkPackage.Data)
+	case PackageFormatChangeObjectStates, PackageFormatEvents:
+		return store.ApplyChanges(networkPackage.HostId, &networkPackage.Data)
+	default:
+		return nil
+	}
+}
+
+func (store *ObjectStateStore) ApplyFullState(hostId int32, data *DataPackage) error {
+	states, err := data.ParseFullObjectStatePackage()
+	if err != nil {
+		return err
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.hosts[hostId] = &hostObjectStates{states: states, updateTime: data.GetPackageTime()}
+	return nil
+}
+
+func (store *ObjectStateStore) ApplyChanges(hostId int32, data *DataPackage) error {
+	if data.Format != PackageFormatChangeObjectStates && data.Format != PackageFormatEvents {
+		return fmt.Errorf("expected change object states or events package format")
+	}
+
+	events, err := data.ParseEventsPackage()
+	if err != nil {
+		return err
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	host, ok := store.hosts[hostId]
+	if !ok {
+		host = &hostObjectStates{states: make(map[uint32]uint16)}
+		store.hosts[hostId] = host
+	}
+
+	for objectId, state := range events.ObjectStates {
+		host.states[objectId] = state
+	}
+
+	if packageTime := data.GetPackageTime(); packageTime.After(host.updateTime) {
+		host.updateTime = packageTime
+	}
+	return nil
+}
+
+func (store *ObjectStateStore) GetObjectState(hostId int32, objectId uint32) (uint16, bool) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	host, ok := store.hosts[hostId]
+	if !ok {
+		return 0, false
+	}
+	state, ok := host.states[objectId]
+	return state, ok
+}
+
+// GetHostObjectStates возвращает копию состояний всех объектов хоста
+func (store *ObjectStateStore) GetHostObjectStates(hostId int32) map[uint32]uint16 {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	host, ok := store.hosts[hostId]
+	if !ok {
+		return nil
+	}
+
+	result := make(map[uint32]uint16, len(host.states))
+	for objectId, state := range host.states {
+		result[objectId] = state
+	}
+	return result
+}
+
+func (store *ObjectStateStore) GetLastUpdateTime(hostId int32) (time.Time, bool) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	host, ok := store.hosts[hostId]
+	if !ok {
+		return time.Time{}, false
+	}
+	return host.updateTime, true
+}
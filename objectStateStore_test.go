@@ -0,0 +1,55 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestObjectStateStore(t *testing.T) {
+	fullTime := time.Now().Add(-time.Minute)
+	changeTime := time.Now()
+
+	store := NewObjectStateStore()
+
+	err := store.Apply(&NetworkPackage{HostId: 1, Data: DataPackage{
+		Time:   GetUnixMicrosecondsFromTime(fullTime),
+		Format: PackageFormatFullObjectStates,
+		Data: []byte{
+			PackageEventTypeObjectState, 100, 0, 0, 0, 1, 0,
+			PackageEventTypeObjectState, 200, 0, 0, 0, 2, 0}}})
+	assert.Nil(t, err)
+
+	err = store.Apply(&NetworkPackage{HostId: 1, Data: DataPackage{
+		Time:   GetUnixMicrosecondsFromTime(changeTime),
+		Format: PackageFormatChangeObjectStates,
+		Data: []byte{
+			PackageEventTypeObjectState, 200, 0, 0, 0, 3, 0,
+			PackageEventTypeObjectState, 44, 1, 0, 0, 4, 0}}})
+	assert.Nil(t, err)
+
+	state, ok := store.GetObjectState(1, 200)
+	assert.True(t, ok)
+	assert.Equal(t, uint16(3), state)
+
+	_, ok = store.GetObjectState(2, 200)
+	assert.False(t, ok)
+
+	assert.True(t, reflect.DeepEqual(map[uint32]uint16{100: 1, 200: 3, 300: 4}, store.GetHostObjectStates(1)))
+
+	updateTime, ok := store.GetLastUpdateTime(1)
+	assert.True(t, ok)
+	assert.Equal(t, GetUnixMicrosecondsFromTime(changeTime), GetUnixMicrosecondsFromTime(updateTime))
+
+	// Полный срез заменяет все состояния хоста
+	err = store.Apply(&NetworkPackage{HostId: 1, Data: DataPackage{
+		Time:   GetUnixMicrosecondsFromTime(changeTime),
+		Format: PackageFormatFullObjectStates,
+		Data:   []byte{PackageEventTypeObjectState, 100, 0, 0, 0, 5, 0}}})
+	assert.Nil(t, err)
+	assert.True(t, reflect.DeepEqual(map[uint32]uint16{100: 5}, store.GetHostObjectStates(1)))
+
+	err = store.ApplyChanges(1, &DataPackage{Format: PackageFormatHeartbeat})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,202 @@
+package core
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// RecordedLogEntry - сообщение, сохраненное RecordingLogger
+type RecordedLogEntry struct {
+	LogEntry
+	Component string
+	IsFatal   bool // сообщение записано FatalError
+}
+
+// TestingT - часть интерфейса testing.T, используемая функциями проверки
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+type logRecords struct {
+	mutex   sync.Mutex
+	entries []RecordedLogEntry
+}
+
+// RecordingLogger сохраняет сообщения в памяти для проверки в тестах.
+// FatalError не завершает процесс, а вызывает обработчик, заданный WithExitHandler или SetExitHandler.
+type RecordingLogger struct {
+	records   *logRecords
+	levels    *logLevels
+	component string
+	fields    []LogField
+	clock     Clock
+	onExit    *ExitHandler
+}
+
+// NewRecordingLogger создает логгер. Из опций учитываются WithClock и WithExitHandler.
+func NewRecordingLogger(loggerOptions ...LoggerOption) *RecordingLogger {
+	info := &logInfo{}
+	info.applyOptions(loggerOptions)
+
+	onExit := info.onExit
+	return &RecordingLogger{
+		records: &logRecords{},
+		levels:  newLogLevels(LogLevelTrace),
+		clock:   info.clock,
+		onExit:  &onExit}
+}
+
+// SetExitHandler задает обработчик, вызываемый FatalError с кодом 1
+func (logger *RecordingLogger) SetExitHandler(handler ExitHandler) {
+	*logger.onExit = handler
+}
+
+func (logger *RecordingLogger) record(level LogLevel, message string, fields []LogField, isFatal bool) {
+	if !logger.IsEnabled(level) {
+		return
+	}
+
+	// Вызов из метода логгера, которого вызвал проверяемый код
+	_, fn, line, _ := runtime.Caller(2)
+
+	entry := RecordedLogEntry{
+		LogEntry: LogEntry{
+			Time:    getClock(logger.clock).Now(),
+			Level:   level,
+			Caller:  getCallerInfo(fn, line),
+			Message: message,
+			Fields:  appendLogFields(logger.fields, fields)},
+		Component: logger.component,
+		IsFatal:   isFatal}
+
+	logger.records.mutex.Lock()
+	logger.records.entries = append(logger.records.entries, entry)
+	logger.records.mutex.Unlock()
+}
+
+func (logger *RecordingLogger) Clear() {
+}
+
+func (logger *RecordingLogger) Info(message string, fields ...LogField) {
+	logger.record(LogLevelInfo, message, fields, false)
+}
+
+func (logger *RecordingLogger) Error(message string, fields ...LogField) {
+	logger.record(LogLevelError, message, fields, false)
+}
+
+func (logger *RecordingLogger) Warning(message string, fields ...LogField) {
+	logger.record(LogLevelWarning, message, fields, false)
+}
+
+func (logger *RecordingLogger) Debug(message string, fields ...LogField) {
+	logger.record(LogLevelDebug, message, fields, false)
+}
+
+func (logger *RecordingLogger) Trace(message string, fields ...LogField) {
+	logger.record(LogLevelTrace, message, fields, false)
+}
+
+func (logger *RecordingLogger) FatalError(message string, fields ...LogField) {
+	logger.record(LogLevelError, message, fields, true)
+
+	if handler := *logger.onExit; handler != nil {
+		handler(1)
+	}
+}
+
+func (logger *RecordingLogger) IsTraceEnabled() bool {
+	return logger.IsEnabled(LogLevelTrace)
+}
+
+func (logger *RecordingLogger) IsEnabled(level LogLevel) bool {
+	return level <= logger.levels.getLevel(logger.component)
+}
+
+func (logger *RecordingLogger) SetLevel(level LogLevel) {
+	logger.levels.setLevel(logger.component, level)
+}
+
+func (logger *RecordingLogger) With(fields ...LogField) Logger {
+	child := *logger
+	child.fields = appendLogFields(logger.fields, fields)
+	return &child
+}
+
+func (logger *RecordingLogger) Component(name string) Logger {
+	child := *logger
+	child.component = name
+	child.fields = replaceLogField(logger.fields, Field("component", name))
+	return &child
+}
+
+func (logger *RecordingLogger) SetComponentLevel(name string, level LogLevel) {
+	logger.levels.setLevel(name, level)
+}
+
+// Entries возвращает сохраненные сообщения, включая сообщения дочерних логгеров
+func (logger *RecordingLogger) Entries() []RecordedLogEntry {
+	logger.records.mutex.Lock()
+	defer logger.records.mutex.Unlock()
+	return append([]RecordedLogEntry(nil), logger.records.entries...)
+}
+
+// GetEntries возвращает сохраненные сообщения уровня level
+func (logger *RecordingLogger) GetEntries(level LogLevel) []RecordedLogEntry {
+	var result []RecordedLogEntry
+	for _, entry := range logger.Entries() {
+		if entry.Level == level {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// Reset удаляет сохраненные сообщения
+func (logger *RecordingLogger) Reset() {
+	logger.records.mutex.Lock()
+	defer logger.records.mutex.Unlock()
+	logger.records.entries = nil
+}
+
+// HasMessage возвращает true, если было сообщение уровня level, содержащее text
+func (logger *RecordingLogger) HasMessage(level LogLevel, text string) bool {
+	for _, entry := range logger.GetEntries(level) {
+		if strings.Contains(entry.Message, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// AssertLogged проверяет, что было сообщение уровня level, содержащее text
+func (logger *RecordingLogger) AssertLogged(t TestingT, level LogLevel, text string) bool {
+	if logger.HasMessage(level, text) {
+		return true
+	}
+	t.Errorf("expected %s message containing %q, logged: %s", level, text, logger.formatEntries())
+	return false
+}
+
+// AssertNotLogged проверяет, что не было сообщения уровня level, содержащего text
+func (logger *RecordingLogger) AssertNotLogged(t TestingT, level LogLevel, text string) bool {
+	if !logger.HasMessage(level, text) {
+		return true
+	}
+	t.Errorf("unexpected %s message containing %q", level, text)
+	return false
+}
+
+func (logger *RecordingLogger) formatEntries() string {
+	entries := logger.Entries()
+	if len(entries) == 0 {
+		return "nothing"
+	}
+
+	lines := make([]string, 0, len(entries))
+	for i := range entries {
+		lines = append(lines, entries[i].Level.String()+": "+entries[i].messageText())
+	}
+	return "\n" + strings.Join(lines, "\n")
+}
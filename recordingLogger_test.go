@@ -0,0 +1,72 @@
+package core
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingT struct {
+	errors []string
+}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRecordingLogger(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	logger := NewRecordingLogger(WithClock(NewManualClock(start)))
+
+	var exitCode int
+	logger.SetExitHandler(func(code int) {
+		exitCode = code
+	})
+
+	var log Logger = logger
+	log.Component("store").With(DeviceIdField(10)).Warning("segment truncated")
+	log.Info("started")
+	log.FatalError("cannot open")
+
+	entries := logger.Entries()
+	assert.Len(t, entries, 3)
+	assert.Equal(t, LogLevelWarning, entries[0].Level)
+	assert.Equal(t, "store", entries[0].Component)
+	assert.Equal(t, []LogField{Field("component", "store"), DeviceIdField(10)}, entries[0].Fields)
+	assert.True(t, strings.HasPrefix(entries[0].Caller, "recordingLogger_test.go:"))
+	assert.Equal(t, start, entries[1].Time)
+	assert.True(t, entries[2].IsFatal)
+	assert.Equal(t, 1, exitCode)
+
+	assert.True(t, logger.AssertLogged(t, LogLevelWarning, "truncated"))
+	assert.True(t, logger.AssertNotLogged(t, LogLevelError, "started"))
+
+	failing := &recordingT{}
+	assert.False(t, logger.AssertLogged(failing, LogLevelDebug, "started"))
+	assert.False(t, logger.AssertNotLogged(failing, LogLevelInfo, "started"))
+	assert.Len(t, failing.errors, 2)
+
+	logger.SetLevel(LogLevelInfo)
+	log.Debug("hidden")
+	assert.Len(t, logger.GetEntries(LogLevelDebug), 0)
+
+	logger.Reset()
+	assert.Empty(t, logger.Entries())
+}
+
+func TestFatalErrorExitHandler(t *testing.T) {
+	memory := NewMemoryLogOutput(LogLevelTrace, 10)
+
+	var exitCode int
+	logger := InitLogging([]LogOutput{memory}, WithExitHandler(func(code int) {
+		exitCode = code
+	}))
+
+	logger.FatalError("fatal")
+	assert.Equal(t, 1, exitCode)
+	assert.Len(t, memory.Entries(), 1)
+	assert.Equal(t, LogLevelError, memory.Entries()[0].Level)
+}
@@ -2,66 +2,226 @@ package core
 
 import (
 	"fmt"
-	"gopkg.in/natefinch/lumberjack.v2"
-	"log"
 	"os"
 	"path/filepath"
 	"runtime"
-	"time"
 )
 
 type logInfo struct {
-	traceLogger   *log.Logger
-	infoLogger    *log.Logger
-	warningLogger *log.Logger
-	errorLogger   *log.Logger
-	logLevel      int
-	rollFile      *lumberjack.Logger
+	outputs     []LogOutput
+	levels      *logLevels
+	component   string
+	clock       Clock
+	jsonOutput  bool
+	fields      []LogField
+	rollOptions RollFileOptions
+	onExit      ExitHandler
+	async       *AsyncLogOptions
 }
 
-func (logger *logInfo) Clear() {
-	if logger.rollFile != nil {
-		_ = logger.rollFile.Close()
+// RollFileOptions - параметры ротации файла лога.
+// Нулевые MaxBackups и MaxAge означают отсутствие ограничения.
+type RollFileOptions struct {
+	MaxSize       int         // размер файла в мегабайтах, при превышении выполняется ротация, 0 - 100 мегабайт
+	MaxBackups    int         // количество хранимых архивных файлов
+	MaxAge        int         // время хранения архивных файлов в днях
+	Compress      bool        // сжимать архивные файлы gzip
+	LocalTime     bool        // время в именах архивных файлов - локальное, иначе UTC
+	FileMode      os.FileMode // права на файл лога, 0 - 0666 с учетом umask
+	RotateOnStart bool        // начинать новый файл при создании логгера, если текущий не пуст
+}
+
+func DefaultRollFileOptions() RollFileOptions {
+	return RollFileOptions{
+		MaxSize:    5,
+		MaxBackups: 500,
+		MaxAge:     14,
+		Compress:   true}
+}
+
+// LoggerOption - дополнительная настройка логгера при создании
+type LoggerOption func(logger *logInfo)
+
+// WithClock задает часы для отметок времени сообщений
+func WithClock(clock Clock) LoggerOption {
+	return func(logger *logInfo) {
+		logger.clock = clock
 	}
 }
 
-func (logger *logInfo) addMessageAndCodeInfo(fn string, line int, message string) string {
-	now := time.Now().Format("15:04:05.000")
-	return fmt.Sprintf("%s %s:%d: %s", now, filepath.Base(fn), line, message)
+// ExitHandler вызывается FatalError после записи сообщения вместо завершения процесса
+type ExitHandler func(code int)
+
+// WithExitHandler задает обработчик завершения для FatalError.
+// По умолчанию выводы закрываются и процесс завершается с кодом 1.
+func WithExitHandler(handler ExitHandler) LoggerOption {
+	return func(logger *logInfo) {
+		logger.onExit = handler
+	}
 }
 
-func (logger *logInfo) addMessage(message string) string {
-	now := time.Now().Format("15:04:05.000")
-	return fmt.Sprintf("%s: %s", now, message)
+// WithRollFileOptions задает параметры ротации для InitRollFileLogging
+func WithRollFileOptions(options RollFileOptions) LoggerOption {
+	return func(logger *logInfo) {
+		logger.rollOptions = options
+	}
 }
 
-func (logger *logInfo) Trace(message string) {
-	if !logger.IsTraceEnabled() {
+// WithOutput добавляет вывод к выводам, создаваемым функцией инициализации
+func WithOutput(output LogOutput) LoggerOption {
+	return func(logger *logInfo) {
+		logger.outputs = append(logger.outputs, output)
+	}
+}
+
+// WithAsync включает асинхронную запись во все выводы логгера.
+// Clear выводит сообщения из очередей перед закрытием выводов.
+func WithAsync(options AsyncLogOptions) LoggerOption {
+	return func(logger *logInfo) {
+		logger.async = &options
+	}
+}
+
+// WithJsonOutput включает вывод сообщений в виде JSON-объектов, по одному в строке
+func WithJsonOutput() LoggerOption {
+	return func(logger *logInfo) {
+		logger.jsonOutput = true
+	}
+}
+
+func (logger *logInfo) applyOptions(options []LoggerOption) {
+	for _, option := range options {
+		option(logger)
+	}
+}
+
+// setupAsync оборачивает выводы в асинхронные, если задана опция WithAsync
+func (logger *logInfo) setupAsync() {
+	if logger.async == nil {
 		return
 	}
-	logger.traceLogger.Println(logger.addMessage(message))
+	for i, output := range logger.outputs {
+		logger.outputs[i] = NewAsyncLogOutput(output, *logger.async)
+	}
+}
+
+func (logger *logInfo) Clear() {
+	for _, output := range logger.outputs {
+		_ = output.Close()
+	}
+}
+
+// Rotate начинает новые файлы лога, текущие файлы переименовываются в архивные.
+// Для логгера без файлов ничего не делает.
+func (logger *logInfo) Rotate() error {
+	var result error
+	for _, output := range logger.outputs {
+		if rotatable, ok := output.(RotatableLogOutput); ok {
+			if err := rotatable.Rotate(); err != nil && result == nil {
+				result = err
+			}
+		}
+	}
+	return result
+}
+
+func (logger *logInfo) With(fields ...LogField) Logger {
+	child := *logger
+	child.fields = appendLogFields(logger.fields, fields)
+	return &child
+}
+
+func (logger *logInfo) write(level LogLevel, caller string, message string, fields []LogField) {
+	entry := &LogEntry{
+		Time:    getClock(logger.clock).Now(),
+		Level:   level,
+		Caller:  caller,
+		Message: message,
+		Fields:  appendLogFields(logger.fields, fields)}
+
+	for _, output := range logger.outputs {
+		if level <= output.MinLevel() {
+			_ = output.Write(entry)
+		}
+	}
+}
+
+func getCallerInfo(fn string, line int) string {
+	return fmt.Sprintf("%s:%d", filepath.Base(fn), line)
+}
+
+// Component возвращает логгер компонента, уровень которого можно задать отдельно от общего
+func (logger *logInfo) Component(name string) Logger {
+	child := *logger
+	child.component = name
+	child.fields = replaceLogField(logger.fields, Field("component", name))
+	return &child
+}
+
+// SetLevel задает уровень логгера: для логгера компонента - уровень компонента, иначе общий уровень
+func (logger *logInfo) SetLevel(level LogLevel) {
+	logger.levels.setLevel(logger.component, level)
+}
+
+func (logger *logInfo) SetComponentLevel(name string, level LogLevel) {
+	logger.levels.setLevel(name, level)
+}
+
+func (logger *logInfo) GetLevel() LogLevel {
+	return logger.levels.getLevel(logger.component)
+}
+
+func (logger *logInfo) IsEnabled(level LogLevel) bool {
+	return level <= logger.GetLevel()
 }
 
 func (logger *logInfo) IsTraceEnabled() bool {
-	return logger.logLevel >= 3 && logger.traceLogger != nil
+	return logger.IsEnabled(LogLevelTrace)
+}
+
+func (logger *logInfo) Trace(message string, fields ...LogField) {
+	if !logger.IsEnabled(LogLevelTrace) {
+		return
+	}
+	logger.write(LogLevelTrace, "", message, fields)
+}
+
+func (logger *logInfo) Debug(message string, fields ...LogField) {
+	if !logger.IsEnabled(LogLevelDebug) {
+		return
+	}
+	logger.write(LogLevelDebug, "", message, fields)
 }
 
-func (logger *logInfo) Info(message string) {
-	logger.infoLogger.Println(logger.addMessage(message))
+func (logger *logInfo) Info(message string, fields ...LogField) {
+	if !logger.IsEnabled(LogLevelInfo) {
+		return
+	}
+	logger.write(LogLevelInfo, "", message, fields)
 }
 
-func (logger *logInfo) Error(message string) {
+func (logger *logInfo) Error(message string, fields ...LogField) {
 	_, fn, line, _ := runtime.Caller(1)
-	logger.errorLogger.Println(logger.addMessageAndCodeInfo(fn, line, message))
+	logger.write(LogLevelError, getCallerInfo(fn, line), message, fields)
 }
 
-func (logger *logInfo) Warning(message string) {
-	logger.warningLogger.Println(logger.addMessage(message))
+func (logger *logInfo) Warning(message string, fields ...LogField) {
+	if !logger.IsEnabled(LogLevelWarning) {
+		return
+	}
+	logger.write(LogLevelWarning, "", message, fields)
 }
 
-func (logger *logInfo) FatalError(message string) {
+func (logger *logInfo) FatalError(message string, fields ...LogField) {
 	_, fn, line, _ := runtime.Caller(1)
-	logger.errorLogger.Fatalln(logger.addMessageAndCodeInfo(fn, line, message))
+	logger.write(LogLevelError, getCallerInfo(fn, line), message, fields)
+
+	if logger.onExit != nil {
+		logger.onExit(1)
+		return
+	}
+	logger.Clear()
+	os.Exit(1)
 }
 
 const (
@@ -69,63 +229,59 @@ const (
 	InfoPrefix    = "INFO:    "
 	ErrorPrefix   = "ERROR:   "
 	WarningPrefix = "WARNING: "
+	DebugPrefix   = "DEBUG:   "
 )
 
-func InitDefaultLogging(useTrace bool) Logger {
-	var result = logInfo{}
+func getInitialLogLevel(useTrace bool) LogLevel {
+	if useTrace {
+		return LogLevelTrace
+	}
+	return LogLevelInfo
+}
 
-	options := log.Ldate
+// InitLogging создает логгер с несколькими выводами. Уровень логгера по умолчанию - наибольший
+// из минимальных уровней выводов, каждый вывод получает сообщения не выше своего уровня.
+func InitLogging(outputs []LogOutput, loggerOptions ...LoggerOption) Logger {
+	var result = logInfo{outputs: append([]LogOutput(nil), outputs...)}
+	result.applyOptions(loggerOptions)
 
-	if useTrace {
-		result.logLevel = 3
-		result.traceLogger = log.New(os.Stdout, TracePrefix, options)
-	} else {
-		result.logLevel = 2
+	level := LogLevelError
+	for _, output := range result.outputs {
+		if output.MinLevel() > level {
+			level = output.MinLevel()
+		}
 	}
+	result.levels = newLogLevels(level)
+	result.setupAsync()
 
-	result.infoLogger = log.New(os.Stdout, InfoPrefix, options)
+	return &result
+}
 
-	result.warningLogger = log.New(os.Stdout, WarningPrefix, options)
+func InitDefaultLogging(useTrace bool, loggerOptions ...LoggerOption) Logger {
+	var result = logInfo{}
+	result.applyOptions(loggerOptions)
 
-	result.errorLogger = log.New(os.Stderr, ErrorPrefix, options)
+	result.levels = newLogLevels(getInitialLogLevel(useTrace))
+	result.outputs = append([]LogOutput{
+		NewConsoleLogOutput(LogOutputOptions{MinLevel: LogLevelTrace, JsonOutput: result.jsonOutput})}, result.outputs...)
+	result.setupAsync()
 
 	return &result
 }
 
-func InitRollFileLogging(fileName string, useTrace bool) (Logger, error) {
-	// Проверяем что файл для лога можно открыть или создать
-	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+func InitRollFileLogging(fileName string, useTrace bool, loggerOptions ...LoggerOption) (Logger, error) {
+	var result = logInfo{rollOptions: DefaultRollFileOptions()}
+	result.applyOptions(loggerOptions)
 
+	output, err := NewRollFileLogOutput(fileName,
+		LogOutputOptions{MinLevel: LogLevelTrace, JsonOutput: result.jsonOutput}, result.rollOptions)
 	if err != nil {
 		return nil, err
 	}
 
-	file.Close()
-
-	var rollLogger = &lumberjack.Logger{
-		Filename:   fileName,
-		MaxSize:    5, // megabytes
-		MaxBackups: 500,
-		MaxAge:     14,   //days
-		Compress:   true, // disabled by default
-	}
-
-	var result = logInfo{
-		rollFile: rollLogger,
-	}
-
-	options := log.Ldate
-
-	if useTrace {
-		result.logLevel = 3
-		result.traceLogger = log.New(rollLogger, TracePrefix, options)
-	} else {
-		result.logLevel = 2
-	}
-
-	result.infoLogger = log.New(rollLogger, InfoPrefix, options)
-	result.warningLogger = log.New(rollLogger, WarningPrefix, options)
-	result.errorLogger = log.New(rollLogger, ErrorPrefix, options)
+	result.levels = newLogLevels(getInitialLogLevel(useTrace))
+	result.outputs = append([]LogOutput{output}, result.outputs...)
+	result.setupAsync()
 
 	return &result, nil
 }
@@ -0,0 +1,192 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SensorInfo - описание датчика. Калиброванное значение вычисляется как raw * Scale + Offset,
+// если Scale не задан, он считается равным 1. Min и Max задают допустимый диапазон калиброванного значения.
+type SensorInfo struct {
+	DeviceId    DeviceId `json:"deviceId" yaml:"deviceId"`
+	SensorIndex uint16   `json:"sensor" yaml:"sensor"`
+	Name        string   `json:"name" yaml:"name"`
+	Unit        string   `json:"unit" yaml:"unit"`
+	Scale       *float64 `json:"scale,omitempty" yaml:"scale,omitempty"`
+	Offset      float64  `json:"offset" yaml:"offset"`
+	Min         *float64 `json:"min,omitempty" yaml:"min,omitempty"`
+	Max         *float64 `json:"max,omitempty" yaml:"max,omitempty"`
+}
+
+func (info *SensorInfo) Calibrate(raw float32) float64 {
+	scale := 1.0
+	if info.Scale != nil {
+		scale = *info.Scale
+	}
+	return float64(raw)*scale + info.Offset
+}
+
+func (info *SensorInfo) IsInRange(value float64) bool {
+	if info.Min != nil && value < *info.Min {
+		return false
+	}
+	if info.Max != nil && value > *info.Max {
+		return false
+	}
+	return true
+}
+
+type sensorRegistryConfig struct {
+	Sensors []SensorInfo `json:"sensors" yaml:"sensors"`
+}
+
+// SensorRegistry хранит описания датчиков по устройству и номеру датчика
+type SensorRegistry struct {
+	mutex   sync.RWMutex
+	sensors map[SensorKey]*SensorInfo
+}
+
+func NewSensorRegistry() *SensorRegistry {
+	return &SensorRegistry{sensors: make(map[SensorKey]*SensorInfo)}
+}
+
+// LoadSensorRegistry загружает описания датчиков из файла JSON (.json) или YAML (.yaml, .yml)
+func LoadSensorRegistry(fileName string) (*SensorRegistry, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json":
+		return ParseSensorRegistryJson(content)
+	case ".yaml", ".yml":
+		return ParseSensorRegistryYaml(content)
+	default:
+		return nil, fmt.Errorf("unknown sensor registry file format %s", fileName)
+	}
+}
+
+func ParseSensorRegistryJson(content []byte) (*SensorRegistry, error) {
+	var config sensorRegistryConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, err
+	}
+	return newSensorRegistryFromConfig(&config)
+}
+
+func ParseSensorRegistryYaml(content []byte) (*SensorRegistry, error) {
+	var config sensorRegistryConfig
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, err
+	}
+	return newSensorRegistryFromConfig(&config)
+}
+
+func newSensorRegistryFromConfig(config *sensorRegistryConfig) (*SensorRegistry, error) {
+	registry := NewSensorRegistry()
+	for i := range config.Sensors {
+		if err := registry.Add(config.Sensors[i]); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+func (registry *SensorRegistry) Add(info SensorInfo) error {
+	if info.Min != nil && info.Max != nil && *info.Min > *info.Max {
+		return fmt.Errorf("sensor %d of device %d has min greater than max", info.SensorIndex, info.DeviceId)
+	}
+
+	key := SensorKey{DeviceId: info.DeviceId, SensorIndex: info.SensorIndex}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if _, ok := registry.sensors[key]; ok {
+		return fmt.Errorf("duplicate sensor %d of device %d", info.SensorIndex, info.DeviceId)
+	}
+	registry.sensors[key] = &info
+	return nil
+}
+
+func (registry *SensorRegistry) Get(key SensorKey) (SensorInfo, bool) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	info, ok := registry.sensors[key]
+	if !ok {
+		return SensorInfo{}, false
+	}
+	return *info, true
+}
+
+// Measurement - значение датчика из пакета измерений.
+// Для датчиков, отсутствующих в реестре, IsKnown = false и Value равно исходному значению.
+type Measurement struct {
+	DeviceId     DeviceId
+	SensorIndex  uint16
+	Name         string
+	Unit         string
+	Time         time.Time
+	RawValue     float32
+	Value        float64
+	IsUndefined  bool
+	IsOutOfRange bool
+	IsKnown      bool
+}
+
+// GetMeasurements возвращает калиброванные значения всех датчиков пакета
+func (data *DataPackage) GetMeasurements(registry *SensorRegistry) ([]Measurement, error) {
+	if data.Format != PackageFormatData {
+		return nil, fmt.Errorf("expected data package format")
+	}
+	if data.IsHostPackage() {
+		return nil, fmt.Errorf("host package %s has no measurements", data.DeviceId)
+	}
+	if data.IsCompressed() {
+		return nil, fmt.Errorf("compressed data package is not supported")
+	}
+
+	converter, err := GetDataConverterFunction(data.BitsPerSensor)
+	if err != nil {
+		return nil, err
+	}
+
+	packageTime := data.GetPackageTime()
+
+	result := make([]Measurement, 0, data.SensorCount)
+	for sensorId := uint16(0); sensorId < data.SensorCount; sensorId++ {
+		raw := converter(data.Data, sensorId)
+
+		measurement := Measurement{
+			DeviceId:    data.DeviceId,
+			SensorIndex: sensorId,
+			Time:        packageTime,
+			RawValue:    raw,
+			Value:       float64(raw),
+			IsUndefined: IsNaN(raw)}
+
+		if registry != nil {
+			if info, ok := registry.Get(SensorKey{DeviceId: data.DeviceId, SensorIndex: sensorId}); ok {
+				measurement.IsKnown = true
+				measurement.Name = info.Name
+				measurement.Unit = info.Unit
+				if !measurement.IsUndefined {
+					measurement.Value = info.Calibrate(raw)
+					measurement.IsOutOfRange = !info.IsInRange(measurement.Value)
+				}
+			}
+		}
+
+		result = append(result, measurement)
+	}
+
+	return result, nil
+}
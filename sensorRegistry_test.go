@@ -0,0 +1,94 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseSensorRegistry(t *testing.T) {
+	jsonConfig := `{"sensors": [
+		{"deviceId": 10, "sensor": 0, "name": "U1", "unit": "V", "scale": 2, "offset": 1, "min": 0, "max": 10},
+		{"deviceId": 10, "sensor": 1, "name": "I1", "unit": "A"}]}`
+
+	yamlConfig := `
+sensors:
+  - deviceId: 10
+    sensor: 0
+    name: U1
+    unit: V
+    scale: 2
+    offset: 1
+    min: 0
+    max: 10
+  - deviceId: 10
+    sensor: 1
+    name: I1
+    unit: A
+`
+
+	for name, parse := range map[string]func() (*SensorRegistry, error){
+		"json": func() (*SensorRegistry, error) { return ParseSensorRegistryJson([]byte(jsonConfig)) },
+		"yaml": func() (*SensorRegistry, error) { return ParseSensorRegistryYaml([]byte(yamlConfig)) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			registry, err := parse()
+			assert.Nil(t, err)
+
+			info, ok := registry.Get(SensorKey{DeviceId: 10, SensorIndex: 0})
+			assert.True(t, ok)
+			assert.Equal(t, "U1", info.Name)
+			assert.Equal(t, "V", info.Unit)
+			assert.Equal(t, float64(10), *info.Max)
+			assert.Equal(t, float64(2), *info.Scale)
+
+			info, ok = registry.Get(SensorKey{DeviceId: 10, SensorIndex: 1})
+			assert.True(t, ok)
+			assert.Nil(t, info.Scale)
+
+			_, ok = registry.Get(SensorKey{DeviceId: 10, SensorIndex: 2})
+			assert.False(t, ok)
+		})
+	}
+
+	_, err := ParseSensorRegistryJson([]byte(`{"sensors": [{"deviceId": 1, "sensor": 0}, {"deviceId": 1, "sensor": 0}]}`))
+	assert.NotNil(t, err)
+}
+
+func TestGetMeasurements(t *testing.T) {
+	maxValue := 5.0
+	scale := 2.0
+	zeroScale := 0.0
+	registry := NewSensorRegistry()
+	assert.Nil(t, registry.Add(SensorInfo{DeviceId: 10, SensorIndex: 0, Name: "U1", Unit: "V", Scale: &scale, Offset: 1, Max: &maxValue}))
+	assert.Nil(t, registry.Add(SensorInfo{DeviceId: 10, SensorIndex: 1, Name: "I1", Unit: "A"}))
+	assert.Nil(t, registry.Add(SensorInfo{DeviceId: 11, SensorIndex: 0, Name: "Z", Scale: &zeroScale, Offset: 3}))
+
+	packageTime := time.Now()
+	measurements, err := makeMeasurePackage(10, packageTime, 1500, SystemUndefined16BitValue, 250).GetMeasurements(registry)
+	assert.Nil(t, err)
+	assert.Len(t, measurements, 3)
+
+	assert.Equal(t, "U1", measurements[0].Name)
+	assert.Equal(t, float64(4), measurements[0].Value)
+	assert.True(t, measurements[0].IsKnown)
+	assert.False(t, measurements[0].IsOutOfRange)
+
+	assert.Equal(t, "A", measurements[1].Unit)
+	assert.True(t, measurements[1].IsUndefined)
+
+	assert.False(t, measurements[2].IsKnown)
+	assert.Equal(t, float64(float32(0.25)), measurements[2].Value)
+
+	measurements, err = makeMeasurePackage(10, packageTime, 3000).GetMeasurements(registry)
+	assert.Nil(t, err)
+	assert.True(t, measurements[0].IsOutOfRange)
+
+	// Нулевой масштаб задается явно и не заменяется на 1
+	measurements, err = makeMeasurePackage(11, packageTime, 3000).GetMeasurements(registry)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(3), measurements[0].Value)
+
+	_, err = (&DataPackage{Format: PackageFormatHeartbeat}).GetMeasurements(registry)
+	assert.NotNil(t, err)
+}
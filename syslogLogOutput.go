@@ -0,0 +1,27 @@
+package core
+
+import (
+	"fmt"
+	"os"
+)
+
+// Сокеты локального демона syslog, проверяются по порядку
+var localSyslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// NewSyslogLogOutput возвращает вывод в локальный syslog через сокет демона syslog.
+// Сообщения передаются в формате локального демона (как в log/syslog), пустой tag - имя исполняемого файла.
+func NewSyslogLogOutput(options LogOutputOptions, facility SyslogFacility, tag string) (LogOutput, error) {
+	for _, address := range localSyslogSockets {
+		if _, err := os.Stat(address); err != nil {
+			continue
+		}
+		return NewSyslogNetworkOutput(SyslogNetworkOptions{
+			LogOutputOptions: options,
+			Network:          "unixgram",
+			Address:          address,
+			Facility:         facility,
+			AppName:          tag,
+			LocalFormat:      true})
+	}
+	return nil, fmt.Errorf("local syslog socket not found")
+}
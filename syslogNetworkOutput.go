@@ -0,0 +1,352 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SyslogFacility - источник сообщения syslog (RFC 5424, раздел 6.2.1).
+// Источник kern (0) зарезервирован для ядра, нулевое значение означает SyslogFacilityUser.
+type SyslogFacility int
+
+const (
+	SyslogFacilityUser   SyslogFacility = 1
+	SyslogFacilityDaemon SyslogFacility = 3
+	SyslogFacilityLocal0 SyslogFacility = 16
+	SyslogFacilityLocal1 SyslogFacility = 17
+	SyslogFacilityLocal2 SyslogFacility = 18
+	SyslogFacilityLocal3 SyslogFacility = 19
+	SyslogFacilityLocal4 SyslogFacility = 20
+	SyslogFacilityLocal5 SyslogFacility = 21
+	SyslogFacilityLocal6 SyslogFacility = 22
+	SyslogFacilityLocal7 SyslogFacility = 23
+)
+
+const (
+	DefaultSyslogBufferSize        = 1000
+	DefaultSyslogReconnectInterval = time.Second * 5
+	DefaultSyslogTimeout           = time.Second * 5
+)
+
+// Идентификатор элемента структурированных данных для полей сообщения.
+// 32473 - номер предприятия, зарезервированный для примеров (RFC 5612).
+const syslogFieldsSdId = "fields@32473"
+
+// SyslogNetworkOptions - параметры вывода в syslog по RFC 5424
+type SyslogNetworkOptions struct {
+	LogOutputOptions
+	Network           string         // "udp", "tcp", "unix" или "unixgram"
+	Address           string         // адрес сборщика, для unix - путь к сокету
+	Facility          SyslogFacility // SyslogFacility*, SyslogFacilityUser если 0
+	AppName           string         // имя приложения, имя исполняемого файла если пусто
+	Hostname          string         // имя хоста, os.Hostname() если пусто
+	BufferSize        int            // количество сообщений, хранимых при недоступности сборщика, DefaultSyslogBufferSize если 0
+	ReconnectInterval time.Duration  // пауза между попытками подключения, DefaultSyslogReconnectInterval если 0
+	Timeout           time.Duration  // таймаут подключения и записи, DefaultSyslogTimeout если 0
+	Clock             Clock          // часы для пауз между попытками подключения
+	// Формат локального демона syslog "<PRI>TIMESTAMP APP-NAME[PROCID]: MSG" вместо RFC 5424
+	LocalFormat bool
+}
+
+// SyslogNetworkOutput передает сообщения сборщику syslog в формате RFC 5424 или локальному демону syslog.
+// Сообщения передаются в фоне, при недоступности сборщика хранятся в буфере,
+// при переполнении буфера отбрасываются самые старые.
+type SyslogNetworkOutput struct {
+	options  SyslogNetworkOptions
+	isStream bool
+	procId   string
+
+	mutex   sync.Mutex
+	queue   [][]byte
+	head    uint64 // количество сообщений, удаленных из начала буфера
+	dropped uint64
+
+	conn      net.Conn
+	wakeup    chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewSyslogNetworkOutput(options SyslogNetworkOptions) (*SyslogNetworkOutput, error) {
+	var isStream bool
+	switch options.Network {
+	case "tcp", "unix":
+		isStream = true
+	case "udp", "unixgram":
+	default:
+		return nil, fmt.Errorf("unsupported syslog network %q", options.Network)
+	}
+
+	if options.Facility < 0 || options.Facility > SyslogFacilityLocal7 {
+		return nil, fmt.Errorf("invalid syslog facility %d", options.Facility)
+	}
+	if options.Facility == 0 {
+		options.Facility = SyslogFacilityUser
+	}
+
+	if options.AppName == "" {
+		options.AppName = filepath.Base(os.Args[0])
+	}
+	if options.Hostname == "" {
+		options.Hostname, _ = os.Hostname()
+	}
+	if options.BufferSize <= 0 {
+		options.BufferSize = DefaultSyslogBufferSize
+	}
+	if options.ReconnectInterval <= 0 {
+		options.ReconnectInterval = DefaultSyslogReconnectInterval
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultSyslogTimeout
+	}
+
+	result := &SyslogNetworkOutput{
+		options:  options,
+		isStream: isStream,
+		procId:   strconv.Itoa(os.Getpid()),
+		wakeup:   make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{})}
+
+	go result.run()
+	return result, nil
+}
+
+// InitSyslogLogging создает логгер, передающий сообщения сборщику syslog
+func InitSyslogLogging(options SyslogNetworkOptions, loggerOptions ...LoggerOption) (Logger, error) {
+	output, err := NewSyslogNetworkOutput(options)
+	if err != nil {
+		return nil, err
+	}
+	return InitLogging([]LogOutput{output}, loggerOptions...), nil
+}
+
+func (output *SyslogNetworkOutput) MinLevel() LogLevel {
+	return output.options.MinLevel
+}
+
+// Write помещает сообщение в буфер передачи. Формирование и добавление в буфер выполняются
+// под одной блокировкой, чтобы сообщения передавались в порядке вызовов Write.
+func (output *SyslogNetworkOutput) Write(entry *LogEntry) error {
+	output.mutex.Lock()
+	message := output.formatMessage(entry)
+	if len(output.queue) >= output.options.BufferSize {
+		output.queue = output.queue[1:]
+		output.head++
+		atomic.AddUint64(&output.dropped, 1)
+	}
+	output.queue = append(output.queue, message)
+	output.mutex.Unlock()
+
+	select {
+	case output.wakeup <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Dropped возвращает количество сообщений, отброшенных из-за переполнения буфера
+func (output *SyslogNetworkOutput) Dropped() uint64 {
+	return atomic.LoadUint64(&output.dropped)
+}
+
+// Close пытается передать оставшиеся сообщения и закрывает подключение
+func (output *SyslogNetworkOutput) Close() error {
+	output.closeOnce.Do(func() {
+		close(output.stop)
+		<-output.done
+	})
+	return nil
+}
+
+func (output *SyslogNetworkOutput) run() {
+	defer close(output.done)
+	defer output.disconnect()
+
+	for {
+		select {
+		case <-output.stop:
+			output.send(false)
+			return
+		case <-output.wakeup:
+			output.send(true)
+		}
+	}
+}
+
+// send передает сообщения из буфера. При ошибке повторяет попытки через ReconnectInterval,
+// если retry = true, иначе оставляет сообщения в буфере.
+func (output *SyslogNetworkOutput) send(retry bool) {
+	for {
+		output.mutex.Lock()
+		if len(output.queue) == 0 {
+			output.mutex.Unlock()
+			return
+		}
+		message, head := output.queue[0], output.head
+		output.mutex.Unlock()
+
+		if err := output.sendMessage(message); err != nil {
+			output.disconnect()
+			if !retry {
+				return
+			}
+
+			timer := getClock(output.options.Clock).NewTimer(output.options.ReconnectInterval)
+			select {
+			case <-output.stop:
+				timer.Stop()
+				output.send(false)
+				return
+			case <-timer.C():
+			}
+			continue
+		}
+
+		output.mutex.Lock()
+		// Переданное сообщение могло быть отброшено при переполнении во время передачи
+		if output.head == head {
+			output.queue = output.queue[1:]
+			output.head++
+		}
+		output.mutex.Unlock()
+	}
+}
+
+func (output *SyslogNetworkOutput) sendMessage(message []byte) error {
+	if output.conn == nil {
+		conn, err := net.DialTimeout(output.options.Network, output.options.Address, output.options.Timeout)
+		if err != nil {
+			return err
+		}
+		output.conn = conn
+	}
+
+	if err := output.conn.SetWriteDeadline(time.Now().Add(output.options.Timeout)); err != nil {
+		return err
+	}
+
+	if output.isStream {
+		// Octet counting по RFC 6587
+		_, err := output.conn.Write(append([]byte(strconv.Itoa(len(message))+" "), message...))
+		return err
+	}
+
+	_, err := output.conn.Write(message)
+	return err
+}
+
+func (output *SyslogNetworkOutput) disconnect() {
+	if output.conn != nil {
+		_ = output.conn.Close()
+		output.conn = nil
+	}
+}
+
+// getSyslogSeverity возвращает уровень важности syslog для уровня лога
+func getSyslogSeverity(level LogLevel) int {
+	switch level {
+	case LogLevelError:
+		return 3
+	case LogLevelWarning:
+		return 4
+	case LogLevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// formatMessage формирует сообщение RFC 5424:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG.
+// Поля передаются в STRUCTURED-DATA, MSG содержит только место вызова и текст сообщения.
+func (output *SyslogNetworkOutput) formatMessage(entry *LogEntry) []byte {
+	var buffer bytes.Buffer
+
+	priority := int(output.options.Facility)*8 + getSyslogSeverity(entry.Level)
+
+	if output.options.LocalFormat {
+		fmt.Fprintf(&buffer, "<%d>%s %s[%s]: ", priority, entry.Time.Format(time.Stamp),
+			getSyslogHeaderField(output.options.AppName, 48), output.procId)
+		if output.options.JsonOutput {
+			buffer.WriteString(entry.Json())
+		} else {
+			buffer.WriteString(entry.messageText())
+		}
+		return buffer.Bytes()
+	}
+
+	fmt.Fprintf(&buffer, "<%d>1 %s %s %s %s - ",
+		priority,
+		entry.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		getSyslogHeaderField(output.options.Hostname, 255),
+		getSyslogHeaderField(output.options.AppName, 48),
+		getSyslogHeaderField(output.procId, 128))
+
+	writeSyslogStructuredData(&buffer, entry.Fields)
+
+	buffer.WriteByte(' ')
+	if output.options.JsonOutput {
+		buffer.WriteString(entry.Json())
+	} else if entry.Caller != "" {
+		buffer.WriteString(entry.Caller + ": " + entry.Message)
+	} else {
+		buffer.WriteString(entry.Message)
+	}
+
+	return buffer.Bytes()
+}
+
+// getSyslogHeaderField оставляет в поле заголовка только печатные символы ASCII
+func getSyslogHeaderField(value string, maxLength int) string {
+	result := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+
+	if result == "" {
+		return "-"
+	}
+	if len(result) > maxLength {
+		return result[:maxLength]
+	}
+	return result
+}
+
+func writeSyslogStructuredData(buffer *bytes.Buffer, fields []LogField) {
+	if len(fields) == 0 {
+		buffer.WriteByte('-')
+		return
+	}
+
+	buffer.WriteString("[" + syslogFieldsSdId)
+	for _, field := range fields {
+		name := strings.Map(func(r rune) rune {
+			if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' {
+				return '_'
+			}
+			return r
+		}, field.Key)
+		if len(name) > 32 {
+			name = name[:32]
+		}
+		if name == "" {
+			name = "_"
+		}
+
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(formatLogFieldValue(field.Value))
+		fmt.Fprintf(buffer, ` %s="%s"`, name, value)
+	}
+	buffer.WriteByte(']')
+}
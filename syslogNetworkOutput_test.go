@@ -0,0 +1,170 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readSyslogFrame читает сообщение, переданное с octet counting
+func readSyslogFrame(t *testing.T, reader *bufio.Reader) string {
+	length, err := reader.ReadString(' ')
+	assert.Nil(t, err)
+
+	size, err := strconv.Atoi(strings.TrimSpace(length))
+	assert.Nil(t, err)
+
+	message := make([]byte, size)
+	_, err = io.ReadFull(reader, message)
+	assert.Nil(t, err)
+	return string(message)
+}
+
+func TestSyslogNetworkOutputTcp(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	logger, err := InitSyslogLogging(SyslogNetworkOptions{
+		LogOutputOptions: LogOutputOptions{MinLevel: LogLevelDebug},
+		Network:          "tcp",
+		Address:          listener.Addr().String(),
+		Facility:         SyslogFacilityLocal0,
+		AppName:          "apkdk",
+		Hostname:         "server"},
+		WithClock(NewManualClock(time.Date(2020, 1, 1, 10, 20, 30, 0, time.UTC))))
+	assert.Nil(t, err)
+
+	logger.Warning("no connection", HostIdField(1), Field("reason", `say "hi"]`))
+	logger.Debug("debug")
+	logger.Trace("hidden")
+
+	conn, err := listener.Accept()
+	assert.Nil(t, err)
+	defer conn.Close()
+	assert.Nil(t, conn.SetReadDeadline(time.Now().Add(time.Second*5)))
+
+	reader := bufio.NewReader(conn)
+	assert.Regexp(t, regexp.MustCompile(`^<132>1 2020-01-01T10:20:30\.000000Z server apkdk \d+ - `+
+		`\[fields@32473 hostId="1" reason="say \\"hi\\"\\]"\] no connection$`), readSyslogFrame(t, reader))
+	assert.Regexp(t, regexp.MustCompile(`^<135>1 .* - - debug$`), readSyslogFrame(t, reader))
+
+	logger.Clear()
+}
+
+func TestSyslogNetworkOutputReconnect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syslog")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "syslog.sock")
+
+	output, err := NewSyslogNetworkOutput(SyslogNetworkOptions{
+		LogOutputOptions:  LogOutputOptions{MinLevel: LogLevelInfo},
+		Network:           "unix",
+		Address:           socket,
+		BufferSize:        2,
+		ReconnectInterval: time.Millisecond * 10})
+	assert.Nil(t, err)
+
+	// Сборщик недоступен: сообщения буферизуются, старые отбрасываются
+	logger := InitLogging([]LogOutput{output})
+	logger.Info("first")
+	logger.Info("second")
+	logger.Info("third")
+	assert.Equal(t, uint64(1), output.Dropped())
+
+	listener, err := net.Listen("unix", socket)
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	conn, err := listener.Accept()
+	assert.Nil(t, err)
+	defer conn.Close()
+	assert.Nil(t, conn.SetReadDeadline(time.Now().Add(time.Second*5)))
+
+	reader := bufio.NewReader(conn)
+	assert.True(t, strings.HasSuffix(readSyslogFrame(t, reader), " - - second"))
+	assert.True(t, strings.HasSuffix(readSyslogFrame(t, reader), " - - third"))
+
+	assert.Nil(t, output.Close())
+}
+
+func TestSyslogNetworkOutputUdp(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	logger, err := InitSyslogLogging(SyslogNetworkOptions{
+		LogOutputOptions: LogOutputOptions{MinLevel: LogLevelTrace, JsonOutput: true},
+		Network:          "udp",
+		Address:          listener.LocalAddr().String(),
+		Facility:         SyslogFacilityUser})
+	assert.Nil(t, err)
+
+	logger.Error("failed")
+
+	assert.Nil(t, listener.SetReadDeadline(time.Now().Add(time.Second*5)))
+	buffer := make([]byte, 2048)
+	size, _, err := listener.ReadFrom(buffer)
+	assert.Nil(t, err)
+
+	message := string(buffer[:size])
+	assert.True(t, strings.HasPrefix(message, "<11>1 "))
+	assert.Contains(t, message, ` - - {"time":`)
+	assert.Contains(t, message, `"message":"failed"`)
+
+	logger.Clear()
+
+	_, err = NewSyslogNetworkOutput(SyslogNetworkOptions{Network: "http"})
+	assert.NotNil(t, err)
+	_, err = NewSyslogNetworkOutput(SyslogNetworkOptions{Network: "udp", Facility: 24})
+	assert.NotNil(t, err)
+
+	// Без Facility сообщения передаются от источника user
+	output, err := NewSyslogNetworkOutput(SyslogNetworkOptions{Network: "udp", Address: listener.LocalAddr().String()})
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(output.formatMessage(&LogEntry{Level: LogLevelInfo, Message: "m"})), "<14>1 "))
+	assert.Nil(t, output.Close())
+}
+
+func TestSyslogNetworkOutputLocalFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syslog")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "log")
+	listener, err := net.ListenPacket("unixgram", socketPath)
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	output, err := NewSyslogNetworkOutput(SyslogNetworkOptions{
+		LogOutputOptions: LogOutputOptions{MinLevel: LogLevelTrace},
+		Network:          "unixgram",
+		Address:          socketPath,
+		Facility:         SyslogFacilityDaemon,
+		AppName:          "apkdk",
+		LocalFormat:      true})
+	assert.Nil(t, err)
+	defer output.Close()
+
+	entryTime := time.Date(2020, 1, 2, 10, 20, 30, 0, time.UTC)
+	assert.Nil(t, output.Write(&LogEntry{Time: entryTime, Level: LogLevelWarning, Message: "disk full",
+		Fields: []LogField{HostIdField(1)}}))
+
+	assert.Nil(t, listener.SetReadDeadline(time.Now().Add(time.Second*5)))
+	buffer := make([]byte, 2048)
+	size, _, err := listener.ReadFrom(buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, fmt.Sprintf("<28>Jan  2 10:20:30 apkdk[%d]: disk full hostId=1", os.Getpid()), string(buffer[:size]))
+}
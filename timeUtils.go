@@ -1,6 +1,8 @@
 package core
 
 import (
+	"fmt"
+	"sync"
 	"time"
 )
 
@@ -19,3 +21,98 @@ func GetUnixMillisecondsFromTime(timeValue time.Time) int64 {
 func GetUnixSecondsFromTime(timeValue time.Time) int64 {
 	return timeValue.Unix()
 }
+
+var displayLocationMutex sync.RWMutex
+var displayLocation = time.Local
+
+// SetDisplayLocation задает часовой пояс для отображения времени (String, JSON, экспорт).
+// nil восстанавливает локальный часовой пояс сервера.
+func SetDisplayLocation(location *time.Location) {
+	if location == nil {
+		location = time.Local
+	}
+
+	displayLocationMutex.Lock()
+	defer displayLocationMutex.Unlock()
+	displayLocation = location
+}
+
+// SetDisplayLocationByName задает часовой пояс отображения по имени, например "Europe/Moscow"
+func SetDisplayLocationByName(name string) error {
+	location, err := LoadTimeLocation(name)
+	if err != nil {
+		return err
+	}
+	SetDisplayLocation(location)
+	return nil
+}
+
+func GetDisplayLocation() *time.Location {
+	displayLocationMutex.RLock()
+	defer displayLocationMutex.RUnlock()
+	return displayLocation
+}
+
+// LoadTimeLocation возвращает часовой пояс по имени. Пустое имя означает UTC, "Local" - пояс сервера.
+func LoadTimeLocation(name string) (*time.Location, error) {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q: %v", name, err)
+	}
+	return location, nil
+}
+
+// GetTimeFromPackageTime преобразует время пакета в микросекундах в время часового пояса location.
+// Значение 0 означает, что время не задано, и преобразуется в нулевое время.
+// Время до 1970 года хранится как отрицательное значение int64.
+func GetTimeFromPackageTime(mks uint64, location *time.Location) time.Time {
+	if mks == 0 {
+		return time.Time{}
+	}
+	if location == nil {
+		location = GetDisplayLocation()
+	}
+
+	value := int64(mks)
+	seconds := value / 1e6
+	remainder := value % 1e6
+	if remainder < 0 {
+		seconds--
+		remainder += 1e6
+	}
+	return time.Unix(seconds, remainder*1e3).In(location)
+}
+
+// GetPackageTimeFromTime преобразует время в микросекунды для пакета, нулевое время - в 0
+func GetPackageTimeFromTime(value time.Time) uint64 {
+	if value.IsZero() {
+		return 0
+	}
+	return uint64(value.Unix()*1e6 + int64(value.Nanosecond()/1e3))
+}
+
+// FormatPackageTime форматирует время пакета в часовом поясе location (часовой пояс отображения, если nil).
+// Для незаданного времени возвращается пустая строка.
+func FormatPackageTime(mks uint64, layout string, location *time.Location) string {
+	if mks == 0 {
+		return ""
+	}
+	return GetTimeFromPackageTime(mks, location).Format(layout)
+}
+
+// ParsePackageTime разбирает время в часовом поясе location (часовой пояс отображения, если nil)
+// и возвращает время пакета в микросекундах. Пустая строка означает незаданное время.
+func ParsePackageTime(value string, layout string, location *time.Location) (uint64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if location == nil {
+		location = GetDisplayLocation()
+	}
+
+	result, err := time.ParseInLocation(layout, value, location)
+	if err != nil {
+		return 0, err
+	}
+	return GetPackageTimeFromTime(result), nil
+}
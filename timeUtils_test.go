@@ -0,0 +1,63 @@
+package core
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPackageTimeInLocation(t *testing.T) {
+	moscow, err := LoadTimeLocation("Europe/Moscow")
+	assert.Nil(t, err)
+
+	_, err = LoadTimeLocation("Unknown/Zone")
+	assert.NotNil(t, err)
+
+	value := time.Date(2020, 1, 1, 10, 0, 0, 123456000, moscow)
+	mks := GetPackageTimeFromTime(value)
+	assert.Equal(t, GetUnixMicrosecondsFromTime(value), mks)
+	assert.Equal(t, "2020-01-01 10:00:00.123456", FormatPackageTime(mks, "2006-01-02 15:04:05.000000", moscow))
+	assert.Equal(t, "2020-01-01 07:00:00", FormatPackageTime(mks, "2006-01-02 15:04:05", time.UTC))
+
+	parsed, err := ParsePackageTime("2020-01-01 10:00:00.123456", "2006-01-02 15:04:05.000000", moscow)
+	assert.Nil(t, err)
+	assert.Equal(t, mks, parsed)
+
+	// Время до 1970 года
+	value = time.Date(1969, 12, 31, 23, 59, 59, 500000000, time.UTC)
+	mks = GetPackageTimeFromTime(value)
+	assert.Equal(t, int64(-500000), int64(mks))
+	assert.True(t, value.Equal(GetTimeFromPackageTime(mks, time.UTC)))
+
+	// Незаданное время
+	assert.Equal(t, uint64(0), GetPackageTimeFromTime(time.Time{}))
+	assert.True(t, GetTimeFromPackageTime(0, moscow).IsZero())
+	assert.Equal(t, "", FormatPackageTime(0, time.RFC3339, moscow))
+
+	parsed, err = ParsePackageTime("", time.RFC3339, moscow)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), parsed)
+}
+
+func TestDisplayLocation(t *testing.T) {
+	assert.NotNil(t, SetDisplayLocationByName("Unknown/Zone"))
+	assert.Nil(t, SetDisplayLocationByName("Europe/Moscow"))
+	defer SetDisplayLocation(nil)
+
+	data := &DataPackage{DeviceId: 10, Format: PackageFormatHeartbeat,
+		Time: GetPackageTimeFromTime(time.Date(2020, 1, 1, 7, 0, 0, 0, time.UTC))}
+
+	assert.Equal(t, "DevId=10,Format=7,Time=2020-01-01T10:00:00+03:00", data.String())
+
+	content, err := json.Marshal(data)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(content), `"DeviceId":10`))
+	assert.True(t, strings.Contains(string(content), `"PackageTime":"2020-01-01T10:00:00+03:00"`))
+
+	content, err = json.Marshal(&DataPackage{})
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(content), `"PackageTime":""`))
+	assert.Equal(t, "DevId=0,Format=0,Time=", (&DataPackage{}).String())
+}